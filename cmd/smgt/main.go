@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/ribice/smgt/internal/directivecheck"
+	"github.com/ribice/smgt/internal/driver"
+	"github.com/ribice/smgt/loopnow"
+	"github.com/ribice/smgt/rot"
+	"github.com/ribice/smgt/set"
+	"golang.org/x/tools/go/analysis"
+)
+
+func main() {
+	analyzers := []*analysis.Analyzer{
+		rot.NewAnalyzer(),
+		set.NewAnalyzer(),
+		loopnow.NewAnalyzer(),
+	}
+	driver.Main(append(analyzers, directivecheck.NewAnalyzer(analyzers...))...)
+}
@@ -0,0 +1,101 @@
+// Package astutil holds the syntax helpers that the analyzers share:
+// lookups above an inspector cursor, and whether statements leave the code
+// around them.
+package astutil
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// Within reports whether cur is at node or lies below it.
+func Within(cur inspector.Cursor, node ast.Node) bool {
+	if node == nil {
+		return false
+	}
+	for c := range cur.Enclosing() {
+		if c.Node() == node {
+			return true
+		}
+	}
+	return false
+}
+
+// EnclosingStmt returns the innermost statement that is at cur or
+// contains it and for which keep reports true. A nil keep accepts any
+// statement.
+func EnclosingStmt(cur inspector.Cursor, keep func(ast.Stmt) bool) (inspector.Cursor, bool) {
+	for c := range cur.Enclosing() {
+		if stmt, ok := c.Node().(ast.Stmt); ok && (keep == nil || keep(stmt)) {
+			return c, true
+		}
+	}
+	return inspector.Cursor{}, false
+}
+
+// EnclosingFunc returns the innermost function declaration or literal
+// that contains cur.
+func EnclosingFunc(cur inspector.Cursor) (inspector.Cursor, bool) {
+	for c := range cur.Enclosing((*ast.FuncDecl)(nil), (*ast.FuncLit)(nil)) {
+		return c, true
+	}
+	return inspector.Cursor{}, false
+}
+
+// IsPanic reports whether expr calls the panic builtin.
+func IsPanic(expr ast.Expr) bool {
+	call, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := call.Fun.(*ast.Ident)
+	return ok && ident.Name == "panic"
+}
+
+// Terminates reports whether stmt leaves the block around it: it returns,
+// panics or continues the enclosing loop.
+func Terminates(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return s.Tok == token.CONTINUE
+	case *ast.ExprStmt:
+		return IsPanic(s.X)
+	}
+	return false
+}
+
+// BlockTerminates reports whether the last statement of block terminates.
+func BlockTerminates(block *ast.BlockStmt) bool {
+	if block == nil || len(block.List) == 0 {
+		return false
+	}
+	return Terminates(block.List[len(block.List)-1])
+}
+
+// MayExit reports whether node contains a return, goto or panic that can
+// transfer control out of the surrounding statement list. Function
+// literals are not entered.
+func MayExit(node ast.Node) bool {
+	exits := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if exits {
+			return false
+		}
+		switch s := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			exits = true
+		case *ast.BranchStmt:
+			exits = s.Tok == token.GOTO
+		case *ast.CallExpr:
+			exits = IsPanic(s)
+		}
+		return !exits
+	})
+	return exits
+}
@@ -0,0 +1,121 @@
+package astutil
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const src = `package p
+
+func f(xs []int) int {
+	for _, x := range xs {
+		if x < 0 {
+			continue
+		}
+		if x == 0 {
+			panic("zero")
+		}
+		if x > 9 {
+			x--
+		}
+		go func() { return }()
+	}
+	return 0
+}
+`
+
+func parse(t *testing.T) *ast.FuncDecl {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "p.go", src, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	return file.Decls[0].(*ast.FuncDecl)
+}
+
+// find returns the cursor of node in the syntax tree of fn.
+func find(t *testing.T, fn *ast.FuncDecl, node ast.Node) inspector.Cursor {
+	t.Helper()
+	file := &ast.File{Name: ast.NewIdent("p"), Decls: []ast.Decl{fn}}
+	cur, ok := inspector.New([]*ast.File{file}).Root().FindNode(node)
+	if !ok {
+		t.Fatalf("%T not found", node)
+	}
+	return cur
+}
+
+func TestEnclosing(t *testing.T) {
+	fn := parse(t)
+	loop := fn.Body.List[0].(*ast.RangeStmt)
+	guard := loop.Body.List[0].(*ast.IfStmt)
+	cond := find(t, fn, guard.Cond.(*ast.BinaryExpr).X)
+
+	if !Within(cond, loop) || !Within(cond, cond.Node()) || Within(find(t, fn, loop), guard) || Within(cond, nil) {
+		t.Error("Within disagrees with the syntax tree")
+	}
+	if got, ok := EnclosingStmt(cond, nil); !ok || got.Node() != guard {
+		t.Errorf("EnclosingStmt = %v, want the if statement", got.Node())
+	}
+	isRange := func(stmt ast.Stmt) bool {
+		_, ok := stmt.(*ast.RangeStmt)
+		return ok
+	}
+	if got, ok := EnclosingStmt(cond, isRange); !ok || got.Node() != loop {
+		t.Errorf("EnclosingStmt with a filter = %v, want the range statement", got.Node())
+	}
+	if _, ok := EnclosingStmt(find(t, fn, fn.Body), isRange); ok {
+		t.Error("EnclosingStmt found a range statement above the loop")
+	}
+
+	lit := loop.Body.List[3].(*ast.GoStmt).Call.Fun.(*ast.FuncLit)
+	ret := find(t, fn, lit.Body.List[0])
+	if got, ok := EnclosingFunc(ret); !ok || got.Node() != lit {
+		t.Errorf("EnclosingFunc in a literal = %v, want the literal", got.Node())
+	}
+	if got, ok := EnclosingFunc(cond); !ok || got.Node() != fn {
+		t.Errorf("EnclosingFunc = %v, want the declaration", got.Node())
+	}
+}
+
+func TestTerminates(t *testing.T) {
+	fn := parse(t)
+	loop := fn.Body.List[0].(*ast.RangeStmt)
+	tests := []struct {
+		block *ast.BlockStmt
+		want  bool
+	}{
+		{loop.Body.List[0].(*ast.IfStmt).Body, true},
+		{loop.Body.List[1].(*ast.IfStmt).Body, true},
+		{loop.Body.List[2].(*ast.IfStmt).Body, false},
+		{&ast.BlockStmt{}, false},
+		{nil, false},
+	}
+	for i, tt := range tests {
+		if got := BlockTerminates(tt.block); got != tt.want {
+			t.Errorf("%d: BlockTerminates = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestMayExit(t *testing.T) {
+	fn := parse(t)
+	loop := fn.Body.List[0].(*ast.RangeStmt)
+	tests := []struct {
+		node ast.Node
+		want bool
+	}{
+		{loop.Body.List[1], true},  // panic
+		{loop.Body.List[2], false}, // x--
+		{loop.Body.List[3], false}, // return inside a function literal
+		{fn.Body.List[1], true},    // return
+	}
+	for i, tt := range tests {
+		if got := MayExit(tt.node); got != tt.want {
+			t.Errorf("%d: MayExit = %v, want %v", i, got, tt.want)
+		}
+	}
+}
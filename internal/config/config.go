@@ -0,0 +1,416 @@
+// Package config loads .smgt.json files, which configure the smgt
+// analyzers for the packages below them.
+//
+// A package uses every .smgt.json from its directory up to the file system
+// root. Files closer to the package override those further up: lists
+// replace, and the severity and analyzers maps are merged key by key.
+// Analyzer flags given on the command line override all files.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ribice/smgt/internal/directive"
+	"golang.org/x/tools/go/analysis"
+)
+
+// FileName is the name of the configuration file.
+const FileName = ".smgt.json"
+
+// analyzers are the analyzers of this module that a file may configure.
+var analyzers = []string{"directive", "loopnow", "rot", "set"}
+
+var severities = []string{"error", "warning", "info", "off"}
+
+// Config is the merged configuration for one directory.
+type Config struct {
+	// Enable, if not empty, lists the only analyzers to run; Disable
+	// lists analyzers to skip.
+	Enable  []string
+	Disable []string
+	// Exclude holds slash-separated glob patterns for files whose
+	// diagnostics are dropped. ** matches any number of directories.
+	// Patterns are relative to the file declaring them and stored as
+	// absolute paths.
+	Exclude []string
+	// Severity maps an analyzer name, or name/category, to error,
+	// warning, info or off.
+	Severity map[string]string
+	// Analyzers holds per-analyzer settings named like the analyzer's
+	// flags.
+	Analyzers map[string]map[string]string
+
+	// sources maps analyzer.setting to the file that set it.
+	sources map[string]string
+}
+
+// file mirrors the JSON layout of a .smgt.json file.
+type file struct {
+	Enable    *[]string                             `json:"enable"`
+	Disable   *[]string                             `json:"disable"`
+	Exclude   *[]string                             `json:"exclude"`
+	Severity  map[string]string                     `json:"severity"`
+	Analyzers map[string]map[string]json.RawMessage `json:"analyzers"`
+}
+
+var (
+	mu    sync.Mutex
+	cache = make(map[string]*entry)
+)
+
+type entry struct {
+	cfg *Config
+	err error
+}
+
+// ForDir returns the configuration for a package in dir.
+func ForDir(dir string) (*Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return forDir(dir)
+}
+
+func forDir(dir string) (*Config, error) {
+	if e, ok := cache[dir]; ok {
+		return e.cfg, e.err
+	}
+	var cfg *Config
+	var err error
+	if parent := filepath.Dir(dir); parent != dir {
+		cfg, err = forDir(parent)
+	} else {
+		cfg = &Config{}
+	}
+	if err == nil {
+		cfg, err = load(dir, cfg)
+	}
+	cache[dir] = &entry{cfg, err}
+	return cfg, err
+}
+
+// load applies the .smgt.json in dir, if any, on top of parent.
+func load(dir string, parent *Config) (*Config, error) {
+	name := filepath.Join(dir, FileName)
+	content, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return parent, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	cfg := &Config{
+		Enable:    parent.Enable,
+		Disable:   parent.Disable,
+		Exclude:   parent.Exclude,
+		Severity:  make(map[string]string),
+		Analyzers: make(map[string]map[string]string),
+		sources:   make(map[string]string),
+	}
+	if f.Enable != nil {
+		cfg.Enable = *f.Enable
+	}
+	if f.Disable != nil {
+		cfg.Disable = *f.Disable
+	}
+	if f.Exclude != nil {
+		cfg.Exclude = nil
+		for _, pattern := range *f.Exclude {
+			cfg.Exclude = append(cfg.Exclude, filepath.ToSlash(filepath.Join(dir, pattern)))
+		}
+	}
+	for key, value := range parent.Severity {
+		cfg.Severity[key] = value
+	}
+	for key, value := range f.Severity {
+		cfg.Severity[key] = value
+	}
+	for key, source := range parent.sources {
+		cfg.sources[key] = source
+	}
+	for analyzer, settings := range parent.Analyzers {
+		cfg.Analyzers[analyzer] = make(map[string]string)
+		for key, value := range settings {
+			cfg.Analyzers[analyzer][key] = value
+		}
+	}
+	for analyzer, settings := range f.Analyzers {
+		if cfg.Analyzers[analyzer] == nil {
+			cfg.Analyzers[analyzer] = make(map[string]string)
+		}
+		for key, raw := range settings {
+			value, err := flagValue(raw)
+			if err != nil {
+				return nil, fmt.Errorf("%s: analyzers.%s.%s: %v", name, analyzer, key, err)
+			}
+			cfg.Analyzers[analyzer][key] = value
+			cfg.sources[analyzer+"."+key] = name
+		}
+	}
+	return cfg, nil
+}
+
+// parse decodes and validates a configuration file.
+func parse(content []byte) (*file, error) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	dec.DisallowUnknownFields()
+	var f file
+	if err := dec.Decode(&f); err != nil {
+		if key, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return nil, fmt.Errorf("unknown key %s; known keys are analyzers, disable, enable, exclude and severity", key)
+		}
+		return nil, err
+	}
+
+	var names []string
+	if f.Enable != nil {
+		names = append(names, *f.Enable...)
+	}
+	if f.Disable != nil {
+		names = append(names, *f.Disable...)
+	}
+	for name := range f.Analyzers {
+		names = append(names, name)
+	}
+	for key, severity := range f.Severity {
+		name, _, _ := strings.Cut(key, "/")
+		names = append(names, name)
+		if !slices.Contains(severities, severity) {
+			return nil, fmt.Errorf("severity %q of %s must be one of %s", severity, key, strings.Join(severities, ", "))
+		}
+	}
+	for _, name := range names {
+		if !slices.Contains(analyzers, name) {
+			return nil, fmt.Errorf("unknown analyzer %q; known analyzers are %s", name, strings.Join(analyzers, ", "))
+		}
+	}
+	if f.Exclude != nil {
+		for _, pattern := range *f.Exclude {
+			if _, err := filepath.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+				return nil, fmt.Errorf("exclude pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return &f, nil
+}
+
+// flagValue turns a JSON scalar, or a list of them, into the text a flag
+// accepts.
+func flagValue(raw json.RawMessage) (string, error) {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		var parts []string
+		for _, elem := range v {
+			s, ok := elem.(string)
+			if !ok {
+				return "", errors.New("lists may only hold strings")
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", errors.New("must be a string, number, boolean or list of strings")
+}
+
+// Enabled reports whether the analyzer runs under this configuration.
+func (c *Config) Enabled(analyzer string) bool {
+	if override != nil {
+		return slices.Contains(override, analyzer)
+	}
+	if len(c.Enable) > 0 && !slices.Contains(c.Enable, analyzer) {
+		return false
+	}
+	return !slices.Contains(c.Disable, analyzer)
+}
+
+// SeverityOf returns the severity of a diagnostic from the analyzer,
+// warning unless the configuration overrides it.
+func (c *Config) SeverityOf(analyzer, category string) string {
+	if category != "" {
+		if s, ok := c.Severity[analyzer+"/"+category]; ok {
+			return s
+		}
+	}
+	if s, ok := c.Severity[analyzer]; ok {
+		return s
+	}
+	return "warning"
+}
+
+// Excluded reports whether diagnostics in the file are dropped.
+func (c *Config) Excluded(filename string) bool {
+	filename = filepath.ToSlash(filename)
+	for _, pattern := range c.Exclude {
+		if match(strings.Split(pattern, "/"), strings.Split(filename, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+func match(pattern, path []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(path); i++ {
+				if match(pattern[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 {
+			return false
+		}
+		if ok, _ := filepath.Match(pattern[0], path[0]); !ok {
+			return false
+		}
+		pattern, path = pattern[1:], path[1:]
+	}
+	// A pattern naming a directory excludes everything below it.
+	return true
+}
+
+// override holds the analyzers selected on the command line, which take
+// precedence over enable and disable in the files.
+var override []string
+
+// SetEnabled records the analyzers selected on the command line. It must
+// be called before analysis starts.
+func SetEnabled(names []string) {
+	override = names
+}
+
+// given holds the values of the flags set on the command line. Analyzer
+// flags are recognised by their value, which drivers share between the
+// analyzer's flag set and their own.
+var given map[flag.Value]bool
+
+// SetFlags records the flags that fs, the flag set of a driver, parsed
+// from the command line. It must be called before analysis starts.
+// Without it, Apply uses the flags of flag.CommandLine, as parsed by the
+// x/tools drivers.
+func SetFlags(fs *flag.FlagSet) {
+	mu.Lock()
+	defer mu.Unlock()
+	setFlags(fs)
+}
+
+func setFlags(fs *flag.FlagSet) {
+	given = make(map[flag.Value]bool)
+	fs.Visit(func(f *flag.Flag) {
+		if reflect.TypeOf(f.Value).Comparable() {
+			given[f.Value] = true
+		}
+	})
+}
+
+// flagGiven reports whether the command line set f, either explicitly or,
+// for drivers that do not report what they parsed, to a value other than
+// the default.
+func flagGiven(f *flag.Flag) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if given == nil && flag.Parsed() {
+		setFlags(flag.CommandLine)
+	}
+	return reflect.TypeOf(f.Value).Comparable() && given[f.Value] || f.Value.String() != f.DefValue
+}
+
+// Apply prepares a pass of an analyzer according to the configuration of
+// its package. register sets a fresh copy of the analyzer's settings to
+// their defaults and registers them on a flag set; Apply then sets them
+// from the files, and from the analyzer flags given on the command line.
+// The returned pass drops diagnostics in excluded files, with severity
+// off, or covered by an //smgt:ignore directive. The usage records the
+// directives that matched, and its Ran field is false if the analyzer is
+// disabled for the package; analyzers return it as their result.
+func Apply(pass *analysis.Pass, register func(*flag.FlagSet)) (*analysis.Pass, *directive.Usage, error) {
+	if len(pass.Files) == 0 {
+		if register != nil {
+			register(flag.NewFlagSet(pass.Analyzer.Name, flag.ContinueOnError))
+		}
+		return pass, &directive.Usage{Ran: true}, nil
+	}
+	name := pass.Fset.File(pass.Files[0].Pos()).Name()
+	cfg, err := ForDir(filepath.Dir(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cfg.Enabled(pass.Analyzer.Name) {
+		return pass, &directive.Usage{}, nil
+	}
+
+	if register != nil {
+		fs := flag.NewFlagSet(pass.Analyzer.Name, flag.ContinueOnError)
+		register(fs)
+		settings := cfg.Analyzers[pass.Analyzer.Name]
+		keys := make([]string, 0, len(settings))
+		for key := range settings {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			source := cfg.sources[pass.Analyzer.Name+"."+key]
+			if fs.Lookup(key) == nil {
+				return nil, nil, fmt.Errorf("%s: unknown setting %q for %s", source, key, pass.Analyzer.Name)
+			}
+			if err := fs.Set(key, settings[key]); err != nil {
+				return nil, nil, fmt.Errorf("%s: analyzers.%s.%s: %v", source, pass.Analyzer.Name, key, err)
+			}
+		}
+		var err error
+		pass.Analyzer.Flags.VisitAll(func(f *flag.Flag) {
+			if err == nil && flagGiven(f) {
+				err = fs.Set(f.Name, f.Value.String())
+			}
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	usage := &directive.Usage{Ran: true}
+	directives := directive.Parse(pass.Fset, pass.Files)
+	filtered := *pass
+	filtered.Report = func(d analysis.Diagnostic) {
+		if cfg.Excluded(pass.Fset.Position(d.Pos).Filename) || cfg.SeverityOf(pass.Analyzer.Name, d.Category) == "off" {
+			return
+		}
+		if usage.Suppress(directives, pass.Analyzer.Name, d.Category, d.Pos) {
+			return
+		}
+		pass.Report(d)
+	}
+	return &filtered, usage, nil
+}
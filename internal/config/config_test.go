@@ -0,0 +1,164 @@
+package config
+
+import (
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+func writeConfig(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("Failed to create %s: %s", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %s", err)
+	}
+}
+
+func TestNested(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, `{
+		"disable": ["rot"],
+		"exclude": ["gen/**"],
+		"severity": {"set": "info", "loopnow": "error"},
+		"analyzers": {"set": {"keys": ["string", "int"], "estimate": true}}
+	}`)
+	nested := filepath.Join(root, "a", "b")
+	writeConfig(t, nested, `{
+		"enable": ["set"],
+		"severity": {"set": "warning"},
+		"analyzers": {"set": {"switch-max": 4}}
+	}`)
+
+	cfg, err := ForDir(filepath.Join(nested, "c"))
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+	if cfg.Enabled("rot") || cfg.Enabled("loopnow") || !cfg.Enabled("set") {
+		t.Errorf("enable %v and disable %v select the wrong analyzers", cfg.Enable, cfg.Disable)
+	}
+	if got := cfg.SeverityOf("set", "switch"); got != "warning" {
+		t.Errorf("severity of set/switch = %s, want the nested warning", got)
+	}
+	if got := cfg.SeverityOf("loopnow", ""); got != "error" {
+		t.Errorf("severity of loopnow = %s, want the inherited error", got)
+	}
+	want := map[string]string{"keys": "string,int", "estimate": "true", "switch-max": "4"}
+	if got := cfg.Analyzers["set"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("set settings = %v, want %v", got, want)
+	}
+	if !cfg.Excluded(filepath.Join(root, "gen", "x", "y.go")) || cfg.Excluded(filepath.Join(nested, "y.go")) {
+		t.Errorf("exclude %v matches the wrong files", cfg.Exclude)
+	}
+}
+
+func TestOverride(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, `{"disable": ["set"]}`)
+	cfg, err := ForDir(root)
+	if err != nil {
+		t.Fatalf("Failed to load config: %s", err)
+	}
+	SetEnabled([]string{"set"})
+	defer SetEnabled(nil)
+	if !cfg.Enabled("set") || cfg.Enabled("rot") {
+		t.Errorf("command-line selection does not override the file")
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`{"enabled": ["set"]}`, `unknown key "enabled"; known keys are`},
+		{`{"disable": ["sets"]}`, `unknown analyzer "sets"; known analyzers are directive, loopnow, rot, set`},
+		{`{"severity": {"set": "fatal"}}`, `severity "fatal" of set must be one of error, warning, info, off`},
+		{`{"analyzers": {"set": {"keys": [1]}}}`, `analyzers.set.keys: lists may only hold strings`},
+		{`{"exclude": ["[a"]}`, `exclude pattern "[a"`},
+		{`{"enable": "set"}`, `cannot unmarshal string`},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		writeConfig(t, dir, tt.content)
+		_, err := ForDir(dir)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("loading %s: got error %v, want %q", tt.content, err, tt.want)
+		}
+		if err != nil && !strings.Contains(err.Error(), filepath.Join(dir, FileName)) {
+			t.Errorf("error %q does not name the file", err)
+		}
+	}
+}
+
+// applyTests runs Apply for a tests flag on a package in dir, with args as
+// the command line, and returns the setting the analyzer would run with.
+func applyTests(t *testing.T, dir string, args ...string) (bool, error) {
+	t.Helper()
+	// Like the analyzers, the flags of a and each run hold separate
+	// settings.
+	var tests, flagTests bool
+	a := &analysis.Analyzer{Name: "loopnow"}
+	a.Flags.BoolVar(&flagTests, "tests", true, "analyze test files")
+	register := func(fs *flag.FlagSet) {
+		fs.BoolVar(&tests, "tests", true, "analyze test files")
+	}
+
+	cmdline := flag.NewFlagSet("smgt", flag.ContinueOnError)
+	a.Flags.VisitAll(func(f *flag.Flag) {
+		cmdline.Var(f.Value, a.Name+"."+f.Name, f.Usage)
+	})
+	if err := cmdline.Parse(args); err != nil {
+		t.Fatalf("Failed to parse flags: %s", err)
+	}
+	SetFlags(cmdline)
+	defer SetFlags(flag.NewFlagSet("none", flag.ContinueOnError))
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filepath.Join(dir, "p.go"), "package p\n", 0)
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	pass := &analysis.Pass{Analyzer: a, Fset: fset, Files: []*ast.File{file}}
+	_, _, err = Apply(pass, register)
+	return tests, err
+}
+
+func TestFlagOverride(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, `{"analyzers": {"loopnow": {"tests": false}}}`)
+
+	if tests, err := applyTests(t, root); err != nil || tests {
+		t.Errorf("without flags: tests = %v, %v; want the file's false", tests, err)
+	}
+	if tests, err := applyTests(t, root, "-loopnow.tests=true"); err != nil || !tests {
+		t.Errorf("-loopnow.tests=true: tests = %v, %v; want true although it is the default", tests, err)
+	}
+}
+
+func TestSettingErrors(t *testing.T) {
+	root := t.TempDir()
+	writeConfig(t, root, `{"analyzers": {"loopnow": {"tests": true}}}`)
+	nested := filepath.Join(root, "a")
+	writeConfig(t, nested, `{"analyzers": {"loopnow": {"test": true}}}`)
+	_, err := applyTests(t, nested)
+	if want := filepath.Join(nested, FileName) + `: unknown setting "test" for loopnow`; err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+
+	other := filepath.Join(root, "b")
+	writeConfig(t, other, `{"analyzers": {"loopnow": {"tests": "maybe"}}}`)
+	_, err = applyTests(t, filepath.Join(other, "c"))
+	if want := filepath.Join(other, FileName) + ": analyzers.loopnow.tests:"; err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("got error %v, want it to start with %q", err, want)
+	}
+}
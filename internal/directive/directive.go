@@ -0,0 +1,198 @@
+// Package directive parses the comments that suppress smgt diagnostics:
+//
+//	//smgt:ignore rot,set -- reason
+//	//smgt:file-ignore loopnow/polling -- reason
+//
+// Each name is an analyzer, or analyzer/category for a single kind of
+// finding. An ignore directive in the doc comment of a function covers the
+// whole function. Elsewhere it covers its own line when it follows code, and
+// the next line when it stands alone. A file-ignore directive covers the
+// whole file.
+package directive
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+const prefix = "//smgt:"
+
+// A Directive is one //smgt: comment.
+type Directive struct {
+	// Pos is the position of the comment.
+	Pos token.Pos
+	// Verb is ignore or file-ignore.
+	Verb   string
+	Names  []string
+	Reason string
+	// Err describes why the directive is malformed; it is empty for
+	// well-formed directives.
+	Err string
+
+	// start and end delimit the code the directive covers.
+	start, end token.Pos
+}
+
+// Covers reports whether the directive suppresses a diagnostic at pos
+// from the analyzer with the category, and if so returns the name that
+// matched.
+func (d *Directive) Covers(analyzer, category string, pos token.Pos) (string, bool) {
+	if pos < d.start || pos > d.end {
+		return "", false
+	}
+	for _, name := range d.Names {
+		if name == analyzer || category != "" && name == analyzer+"/"+category {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Parse returns the directives in files.
+func Parse(fset *token.FileSet, files []*ast.File) []*Directive {
+	var dirs []*Directive
+	for _, file := range files {
+		var found []*Directive
+		for _, group := range file.Comments {
+			for _, c := range group.List {
+				if d := parse(c); d != nil {
+					found = append(found, d)
+				}
+			}
+		}
+		if len(found) > 0 {
+			scope(fset, file, found)
+			dirs = append(dirs, found...)
+		}
+	}
+	return dirs
+}
+
+func parse(c *ast.Comment) *Directive {
+	text, ok := strings.CutPrefix(c.Text, prefix)
+	if !ok {
+		return nil
+	}
+	// A second comment may follow the directive on the same line.
+	text, _, _ = strings.Cut(text, " //")
+	d := &Directive{Pos: c.Pos()}
+	d.Verb, text, _ = strings.Cut(text, " ")
+	if d.Verb != "ignore" && d.Verb != "file-ignore" {
+		d.Err = "unknown directive " + prefix[2:] + d.Verb + "; use smgt:ignore or smgt:file-ignore"
+		return d
+	}
+	names, reason, hasReason := strings.Cut(text, "--")
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, " \t") {
+			d.Names = nil
+			d.Err = "smgt:" + d.Verb + " needs a comma-separated list of analyzers, such as smgt:" + d.Verb + " rot,set -- reason"
+			return d
+		}
+		d.Names = append(d.Names, name)
+	}
+	d.Reason = strings.TrimSpace(reason)
+	if !hasReason || d.Reason == "" {
+		d.Err = "smgt:" + d.Verb + " needs a reason after --"
+	}
+	return d
+}
+
+// scope sets the code each directive of file covers.
+func scope(fset *token.FileSet, file *ast.File, dirs []*Directive) {
+	tf := fset.File(file.Pos())
+	funcs := make(map[*ast.CommentGroup]*ast.FuncDecl)
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Doc != nil {
+			funcs[fn.Doc] = fn
+		}
+	}
+	// Lines holding the start of code, to tell trailing comments from
+	// comments on their own line.
+	code := make(map[int]token.Pos)
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n.(type) {
+		case nil, *ast.File, *ast.CommentGroup, *ast.Comment:
+		default:
+			line := tf.Line(n.Pos())
+			if first, ok := code[line]; !ok || n.Pos() < first {
+				code[line] = n.Pos()
+			}
+		}
+		return true
+	})
+
+	for _, d := range dirs {
+		if d.Verb == "file-ignore" {
+			d.start, d.end = file.FileStart, file.FileEnd
+			continue
+		}
+		if fn := docOf(funcs, file, d.Pos); fn != nil {
+			d.start, d.end = fn.Pos(), fn.End()
+			continue
+		}
+		line := tf.Line(d.Pos)
+		if first, ok := code[line]; !ok || first > d.Pos {
+			line++
+		}
+		if line > tf.LineCount() {
+			continue
+		}
+		d.start = tf.LineStart(line)
+		d.end = file.FileEnd
+		if line < tf.LineCount() {
+			d.end = tf.LineStart(line+1) - 1
+		}
+	}
+}
+
+func docOf(funcs map[*ast.CommentGroup]*ast.FuncDecl, file *ast.File, pos token.Pos) *ast.FuncDecl {
+	for _, group := range file.Comments {
+		if group.Pos() <= pos && pos < group.End() {
+			return funcs[group]
+		}
+	}
+	return nil
+}
+
+// ResultType is the result type of analyzers that honour directives.
+var ResultType = reflect.TypeOf(new(Usage))
+
+// Usage records which directives suppressed diagnostics in one pass of an
+// analyzer. Analyzers return it as their result, so that the directive
+// check can find directives that suppress nothing.
+type Usage struct {
+	// Ran reports whether the analyzer ran on the package; it does not
+	// when the configuration disables it.
+	Ran  bool
+	used map[usageKey]bool
+}
+
+type usageKey struct {
+	pos  token.Pos
+	name string
+}
+
+// Suppress reports whether one of dirs covers the diagnostic, recording
+// the match.
+func (u *Usage) Suppress(dirs []*Directive, analyzer, category string, pos token.Pos) bool {
+	suppressed := false
+	for _, d := range dirs {
+		if name, ok := d.Covers(analyzer, category, pos); ok {
+			if u.used == nil {
+				u.used = make(map[usageKey]bool)
+			}
+			u.used[usageKey{d.Pos, name}] = true
+			suppressed = true
+		}
+	}
+	return suppressed
+}
+
+// Used reports whether the name of the directive at pos suppressed a
+// diagnostic.
+func (u *Usage) Used(pos token.Pos, name string) bool {
+	return u.used[usageKey{pos, name}]
+}
@@ -0,0 +1,106 @@
+package directive_test
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ribice/smgt/internal/directive"
+	"github.com/ribice/smgt/rot"
+	"github.com/ribice/smgt/set"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+const src = `//smgt:file-ignore set -- generated
+package p
+
+//smgt:ignore rot,loopnow/polling -- doc
+func f() {
+	x := 1 //smgt:ignore rot -- trailing
+	//smgt:ignore set/bool-map -- next line
+	y := 2
+	_, _ = x, y
+}
+`
+
+func TestParse(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("Failed to parse: %s", err)
+	}
+	dirs := directive.Parse(fset, []*ast.File{file})
+	if len(dirs) != 4 {
+		t.Fatalf("got %d directives, want 4", len(dirs))
+	}
+	tf := fset.File(file.Pos())
+	pos := func(line int) token.Pos { return tf.LineStart(line) + 1 }
+
+	tests := []struct {
+		dir      int
+		analyzer string
+		category string
+		line     int
+		want     bool
+	}{
+		{0, "set", "bool-map", 1, true},
+		{0, "set", "", 9, true},
+		{0, "rot", "", 9, false},
+		{1, "rot", "declared-early", 5, true},
+		{1, "loopnow", "polling", 9, true},
+		{1, "loopnow", "hoist", 9, false},
+		{1, "rot", "", 1, false},
+		{2, "rot", "", 6, true},
+		{2, "rot", "", 7, false},
+		{3, "set", "bool-map", 8, true},
+		{3, "set", "bool-map", 7, false},
+		{3, "set", "", 8, false},
+	}
+	for _, tt := range tests {
+		_, got := dirs[tt.dir].Covers(tt.analyzer, tt.category, pos(tt.line))
+		if got != tt.want {
+			t.Errorf("directive %d covers %s/%s on line %d = %v, want %v", tt.dir, tt.analyzer, tt.category, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMalformed(t *testing.T) {
+	tests := map[string]string{
+		"//smgt:ignore rot -- reason":         "",
+		"//smgt:ignore rot, set -- reason":    "",
+		"//smgt:ignore rot":                   "smgt:ignore needs a reason after --",
+		"//smgt:ignore rot --":                "smgt:ignore needs a reason after --",
+		"//smgt:ignore -- reason":             "smgt:ignore needs a comma-separated list of analyzers, such as smgt:ignore rot,set -- reason",
+		"//smgt:ignore rot set -- reason":     "smgt:ignore needs a comma-separated list of analyzers, such as smgt:ignore rot,set -- reason",
+		"//smgt:nolint rot -- reason":         "unknown directive smgt:nolint; use smgt:ignore or smgt:file-ignore",
+		"//smgt:file-ignore rot // a comment": "smgt:file-ignore needs a reason after --",
+	}
+	for comment, want := range tests {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "p.go", "package p\n\n"+comment+"\nvar v int\n", parser.ParseComments)
+		if err != nil {
+			t.Fatalf("Failed to parse: %s", err)
+		}
+		dirs := directive.Parse(fset, []*ast.File{file})
+		if len(dirs) != 1 {
+			t.Fatalf("%s: got %d directives, want 1", comment, len(dirs))
+		}
+		if dirs[0].Err != want {
+			t.Errorf("%s: error = %q, want %q", comment, dirs[0].Err, want)
+		}
+	}
+}
+
+func TestSuppressed(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, rot.NewAnalyzer(), "suppressed")
+	analysistest.Run(t, testdata, set.NewAnalyzer(), "fileignore")
+}
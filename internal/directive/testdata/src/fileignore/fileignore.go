@@ -0,0 +1,10 @@
+//smgt:file-ignore set -- the maps mirror the wire format
+package fileignore
+
+func count(keys []string) int {
+	seen := map[string]bool{}
+	for _, k := range keys {
+		seen[k] = true
+	}
+	return len(seen)
+}
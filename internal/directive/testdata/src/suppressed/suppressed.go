@@ -0,0 +1,32 @@
+package suppressed
+
+import "fmt"
+
+// trailing is reported: the directive names another category.
+func trailing(input string) {
+	value := input //smgt:ignore rot/narrow-scope -- wrong category // want `variable value should be declared right before it is used`
+	fmt.Println("unrelated")
+	fmt.Println("unrelated")
+	fmt.Println(value)
+}
+
+// whole keeps its declarations apart.
+//
+//smgt:ignore rot -- declarations grouped for review
+func whole(input string) {
+	first := input
+	fmt.Println("unrelated")
+	fmt.Println("unrelated")
+	fmt.Println(first)
+	second := input
+	fmt.Println("unrelated")
+	fmt.Println("unrelated")
+	fmt.Println(second)
+}
+
+func line(input string) {
+	value := input //smgt:ignore rot/declared-early -- kept apart for the log order
+	fmt.Println("unrelated")
+	fmt.Println("unrelated")
+	fmt.Println(value)
+}
@@ -0,0 +1,73 @@
+// Package directivecheck reports smgt directives that are malformed or
+// suppress nothing. It lives apart from package directive because it is
+// configured like the other analyzers, and the configuration depends on
+// the directive parser.
+package directivecheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ribice/smgt/internal/config"
+	"github.com/ribice/smgt/internal/directive"
+	"golang.org/x/tools/go/analysis"
+)
+
+// NewAnalyzer returns the check for smgt directives. It requires
+// analyzers, whose results must be a *directive.Usage, and reports directives that
+// are malformed, lack a reason, name an analyzer it does not know, or
+// suppress nothing in an analyzer that ran. Its own findings follow the
+// configuration like those of any analyzer.
+func NewAnalyzer(analyzers ...*analysis.Analyzer) *analysis.Analyzer {
+	return &analysis.Analyzer{
+		Name:     "directive",
+		Doc:      "Reports //smgt:ignore and //smgt:file-ignore directives that are malformed, lack a reason or suppress nothing.",
+		URL:      "https://github.com/ribice/smgt#suppressing-findings",
+		Requires: analyzers,
+		Run: func(pass *analysis.Pass) (any, error) {
+			pass, usage, err := config.Apply(pass, nil)
+			if err != nil || !usage.Ran {
+				return nil, err
+			}
+			return nil, check(pass, analyzers)
+		},
+	}
+}
+
+func check(pass *analysis.Pass, analyzers []*analysis.Analyzer) error {
+	usages := make(map[string]*directive.Usage)
+	for _, a := range analyzers {
+		usages[a.Name] = pass.ResultOf[a].(*directive.Usage)
+	}
+	for _, d := range directive.Parse(pass.Fset, pass.Files) {
+		switch {
+		case d.Err != "" && d.Names == nil:
+			pass.Report(analysis.Diagnostic{Pos: d.Pos, Category: "malformed", Message: d.Err})
+			continue
+		case d.Err != "":
+			pass.Report(analysis.Diagnostic{Pos: d.Pos, Category: "no-reason", Message: d.Err})
+		}
+		for _, name := range d.Names {
+			analyzer, _, _ := strings.Cut(name, "/")
+			usage, ok := usages[analyzer]
+			switch {
+			case analyzer == pass.Analyzer.Name:
+				// Directives may suppress this check's own findings,
+				// which are reported while the directives are checked.
+			case !ok:
+				pass.Report(analysis.Diagnostic{
+					Pos:      d.Pos,
+					Category: "malformed",
+					Message:  fmt.Sprintf("smgt:%s names unknown analyzer %q", d.Verb, analyzer),
+				})
+			case usage.Ran && !usage.Used(d.Pos, name):
+				pass.Report(analysis.Diagnostic{
+					Pos:      d.Pos,
+					Category: "unused",
+					Message:  fmt.Sprintf("smgt:%s for %s suppresses nothing; remove it", d.Verb, name),
+				})
+			}
+		}
+	}
+	return nil
+}
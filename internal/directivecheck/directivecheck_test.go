@@ -0,0 +1,32 @@
+package directivecheck_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ribice/smgt/internal/directivecheck"
+	"github.com/ribice/smgt/rot"
+	"github.com/ribice/smgt/set"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAll(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, directivecheck.NewAnalyzer(rot.NewAnalyzer(), set.NewAnalyzer()), "directives")
+}
+
+func TestConfigFile(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, directivecheck.NewAnalyzer(rot.NewAnalyzer()), "directiveconfig", "directiveconfig/disabled")
+}
@@ -0,0 +1,19 @@
+package directiveconfig
+
+import "fmt"
+
+func unused(input string) {
+	//smgt:ignore rot -- unused findings are off
+	fmt.Println(input)
+}
+
+func noReason(input string) {
+	//smgt:ignore rot // want `smgt:ignore needs a reason after --`
+	fmt.Println(input)
+}
+
+func suppressed(input string) {
+	//smgt:ignore directive -- the check's own findings can be ignored
+	//smgt:skip rot -- not a verb
+	fmt.Println(input)
+}
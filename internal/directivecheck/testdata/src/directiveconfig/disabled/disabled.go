@@ -0,0 +1,9 @@
+package disabled
+
+import "fmt"
+
+func disabled(input string) {
+	//smgt:skip rot -- the check is disabled here
+	//smgt:ignore rot // no reason
+	fmt.Println(input)
+}
@@ -0,0 +1,8 @@
+package directiveconfig
+
+import "fmt"
+
+func generated(input string) {
+	//smgt:skip rot -- excluded
+	fmt.Println(input)
+}
@@ -0,0 +1,36 @@
+package directives
+
+import "fmt"
+
+func used(input string) {
+	//smgt:ignore rot -- kept apart for the log order
+	value := input
+	fmt.Println("unrelated")
+	fmt.Println("unrelated")
+	fmt.Println(value)
+}
+
+func unused(input string) {
+	//smgt:ignore rot -- nothing to suppress // want `smgt:ignore for rot suppresses nothing; remove it`
+	fmt.Println(input)
+}
+
+func category(keys []string) {
+	//smgt:ignore set/bool-map -- callers rely on the values // want `smgt:ignore for set/bool-map suppresses nothing; remove it`
+	fmt.Println(len(keys))
+}
+
+func malformed(input string) {
+	//smgt:skip rot -- not a verb // want `unknown directive smgt:skip; use smgt:ignore or smgt:file-ignore`
+	//smgt:ignore -- no names // want `smgt:ignore needs a comma-separated list of analyzers`
+	//smgt:ignore loopnow -- not required // want `smgt:ignore names unknown analyzer "loopnow"`
+	fmt.Println(input)
+}
+
+func noReason(input string) {
+	//smgt:ignore rot // want `smgt:ignore needs a reason after --`
+	value := input
+	fmt.Println("unrelated")
+	fmt.Println("unrelated")
+	fmt.Println(value)
+}
@@ -0,0 +1,210 @@
+package driver
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+)
+
+// A baseline records known findings so that later runs report only new
+// ones. Findings are keyed by position-independent data, so a baseline
+// survives edits elsewhere in the file.
+type baseline struct {
+	Version  int       `json:"version"`
+	Findings []finding `json:"findings"`
+	counts   map[finding]int
+}
+
+type finding struct {
+	Analyzer string `json:"analyzer"`
+	File     string `json:"file"`
+	Func     string `json:"func,omitempty"`
+	Message  string `json:"message"`
+	Count    int    `json:"count,omitempty"`
+}
+
+var numbers = regexp.MustCompile(`[0-9]+`)
+
+// normalizeMessage removes line numbers, byte counts and other numbers
+// that change with unrelated edits.
+func normalizeMessage(msg string) string {
+	return strings.Join(strings.Fields(numbers.ReplaceAllString(msg, "N")), " ")
+}
+
+// findings returns the root diagnostics of graph keyed for a baseline in
+// dir, together with the diagnostic each key came from. A diagnostic
+// reported for both a package and its test variant is counted once.
+func findings(graph *checker.Graph, dir string) map[*checker.Action][]finding {
+	seen := make(map[string]bool)
+	result := make(map[*checker.Action][]finding)
+	for act := range graph.All() {
+		if !act.IsRoot {
+			continue
+		}
+		for _, diag := range act.Diagnostics {
+			posn := act.Package.Fset.Position(diag.Pos)
+			id := act.Analyzer.Name + "\x00" + posn.String() + "\x00" + diag.Message
+			key := newFinding(act, diag, dir)
+			if seen[id] {
+				// Mark duplicates so that they are neither counted nor
+				// reported twice.
+				key.Count = -1
+			}
+			seen[id] = true
+			result[act] = append(result[act], key)
+		}
+	}
+	return result
+}
+
+func newFinding(act *checker.Action, diag analysis.Diagnostic, dir string) finding {
+	name := act.Package.Fset.Position(diag.Pos).Filename
+	if rel, err := filepath.Rel(dir, name); err == nil {
+		name = rel
+	}
+	return finding{
+		Analyzer: act.Analyzer.Name,
+		File:     filepath.ToSlash(name),
+		Func:     enclosingFunc(act.Package.Syntax, diag.Pos),
+		Message:  normalizeMessage(diag.Message),
+	}
+}
+
+// enclosingFunc names the top-level function declaring pos, as Name or
+// Type.Name for methods; it is empty outside functions.
+func enclosingFunc(files []*ast.File, pos token.Pos) string {
+	for _, file := range files {
+		if pos < file.FileStart || pos > file.FileEnd {
+			continue
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || pos < fn.Pos() || pos > fn.End() {
+				continue
+			}
+			if fn.Recv == nil || len(fn.Recv.List) == 0 {
+				return fn.Name.Name
+			}
+			return recvName(fn.Recv.List[0].Type) + "." + fn.Name.Name
+		}
+	}
+	return ""
+}
+
+func recvName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return recvName(expr.X)
+	case *ast.IndexExpr:
+		return recvName(expr.X)
+	case *ast.IndexListExpr:
+		return recvName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+func readBaseline(name string) (*baseline, error) {
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var b baseline
+	if err := json.Unmarshal(content, &b); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if b.Version != 1 {
+		return nil, fmt.Errorf("%s: unsupported baseline version %d", name, b.Version)
+	}
+	b.counts = make(map[finding]int)
+	for _, f := range b.Findings {
+		count := max(f.Count, 1)
+		f.Count = 0
+		b.counts[f] += count
+	}
+	return &b, nil
+}
+
+// writeBaseline records every finding of graph in name, replacing its
+// previous content, so findings that no longer occur are dropped.
+func writeBaseline(name string, graph *checker.Graph) (int, error) {
+	counts := make(map[finding]int)
+	for _, keys := range findings(graph, filepath.Dir(name)) {
+		for _, key := range keys {
+			if key.Count == 0 {
+				counts[key]++
+			}
+		}
+	}
+	b := baseline{Version: 1, Findings: []finding{}}
+	total := 0
+	for key, count := range counts {
+		if count > 1 {
+			key.Count = count
+		}
+		b.Findings = append(b.Findings, key)
+		total += count
+	}
+	sort.Slice(b.Findings, func(i, j int) bool {
+		x, y := b.Findings[i], b.Findings[j]
+		if x.File != y.File {
+			return x.File < y.File
+		}
+		if x.Func != y.Func {
+			return x.Func < y.Func
+		}
+		if x.Analyzer != y.Analyzer {
+			return x.Analyzer < y.Analyzer
+		}
+		return x.Message < y.Message
+	})
+	content, err := json.MarshalIndent(b, "", "\t")
+	if err != nil {
+		return 0, err
+	}
+	return total, os.WriteFile(name, append(content, '\n'), 0o644)
+}
+
+// filter removes the findings recorded in the baseline from the root
+// actions of graph. When a function has more findings with the same key
+// than the baseline records, the extra ones are reported.
+func (b *baseline) filter(graph *checker.Graph, dir string) {
+	remaining := make(map[finding]int, len(b.counts))
+	for key, count := range b.counts {
+		remaining[key] = count
+	}
+	all := findings(graph, dir)
+	acts := make([]*checker.Action, 0, len(all))
+	for act := range all {
+		acts = append(acts, act)
+	}
+	// Suppress in a stable order, so the same findings stay new between
+	// runs.
+	sort.Slice(acts, func(i, j int) bool {
+		return acts[i].String() < acts[j].String()
+	})
+	for _, act := range acts {
+		var kept []analysis.Diagnostic
+		for i, key := range all[act] {
+			if key.Count == -1 {
+				continue
+			}
+			if remaining[key] > 0 {
+				remaining[key]--
+				continue
+			}
+			kept = append(kept, act.Diagnostics[i])
+		}
+		act.Diagnostics = kept
+	}
+}
@@ -0,0 +1,68 @@
+package driver
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+type checkstyleLog struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// writeCheckstyle prints the report in the Checkstyle XML format, one
+// file element per source file. The source of each error is
+// smgt.analyzer, or smgt.analyzer.category when it has a category.
+func writeCheckstyle(w io.Writer, r *report) error {
+	log := checkstyleLog{Version: "4.3"}
+	index := make(map[string]int)
+	for _, it := range r.items {
+		posn := it.position()
+		name := r.path(posn.Filename)
+		i, ok := index[name]
+		if !ok {
+			i = len(log.Files)
+			index[name] = i
+			log.Files = append(log.Files, checkstyleFile{Name: name})
+		}
+		source := "smgt." + it.act.Analyzer.Name
+		if it.diag.Category != "" {
+			source += "." + it.diag.Category
+		}
+		log.Files[i].Errors = append(log.Files[i].Errors, checkstyleError{
+			Line:     posn.Line,
+			Column:   posn.Column,
+			Severity: it.severity,
+			Message:  it.diag.Message,
+			Source:   source,
+		})
+	}
+	return writeXML(w, log)
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
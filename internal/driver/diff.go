@@ -0,0 +1,213 @@
+package driver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+)
+
+// changes holds, per absolute file name, the lines of the new version
+// that a diff adds or modifies.
+type changes map[string]map[int]bool
+
+var hunkHeader = regexp.MustCompile(`^@@ -[0-9]+(?:,([0-9]+))? \+([0-9]+)(?:,([0-9]+))? @@`)
+
+// parseDiff reads a unified diff whose paths are relative to root. A
+// deletion marks the line that follows it in the new version, so a
+// statement that lost a line counts as changed. The b/ prefix of a new
+// name is only dropped when the old name has the matching a/ prefix, so
+// that diffs made with --no-prefix keep their paths.
+func parseDiff(r io.Reader, root string) (changes, error) {
+	result := make(changes)
+	var lines map[int]bool
+	// prefixed reports whether the last --- header had the a/ prefix,
+	// or was /dev/null and says nothing about it.
+	prefixed := true
+	// line is the next line of the new version; old and new count the
+	// lines of the hunk still to come, inside which a line starting with
+	// --- or +++ is content rather than a file header.
+	line, old, new := 0, 0, 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		text := scanner.Text()
+		inHunk := old > 0 || new > 0
+		switch {
+		case !inHunk && strings.HasPrefix(text, "--- "):
+			name, _, _ := strings.Cut(strings.TrimPrefix(text, "--- "), "\t")
+			prefixed = name == "/dev/null" || strings.HasPrefix(name, "a/")
+		case !inHunk && strings.HasPrefix(text, "+++ "):
+			name := strings.TrimPrefix(text, "+++ ")
+			name, _, _ = strings.Cut(name, "\t")
+			if name == "/dev/null" {
+				lines = nil
+				continue
+			}
+			if prefixed {
+				name = strings.TrimPrefix(name, "b/")
+			}
+			name = filepath.Join(root, filepath.FromSlash(name))
+			if result[name] == nil {
+				result[name] = make(map[int]bool)
+			}
+			lines = result[name]
+		case !inHunk && strings.HasPrefix(text, "@@"):
+			m := hunkHeader.FindStringSubmatch(text)
+			if m == nil {
+				return nil, fmt.Errorf("malformed hunk header %q", text)
+			}
+			old, new = hunkCount(m[1]), hunkCount(m[3])
+			line, _ = strconv.Atoi(m[2])
+			if new == 0 {
+				// A hunk that only deletes names the line before the
+				// deletion.
+				line++
+			}
+		case !inHunk:
+		case strings.HasPrefix(text, "+"):
+			if lines != nil {
+				lines[line] = true
+			}
+			line++
+			new--
+		case strings.HasPrefix(text, "-"):
+			if lines != nil {
+				lines[line] = true
+			}
+			old--
+		case strings.HasPrefix(text, " "), text == "":
+			line++
+			old--
+			new--
+		}
+	}
+	return result, scanner.Err()
+}
+
+// hunkCount parses the optional line count of a hunk header, which is 1
+// when omitted.
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// readDiff loads the changes from a diff file, or from git diff against
+// rev when file is empty. git diff leaves out untracked files, so every
+// line of an untracked Go file counts as changed. Paths are resolved
+// against the top of the git repository containing dir, or dir itself
+// outside a repository.
+func readDiff(dir, file, rev string) (changes, error) {
+	root := dir
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	if top, err := git(root, "rev-parse", "--show-toplevel"); err == nil {
+		root = strings.TrimSpace(string(top))
+	} else if rev != "" {
+		return nil, err
+	}
+
+	var diff []byte
+	var err error
+	if file != "" {
+		diff, err = os.ReadFile(file)
+	} else {
+		diff, err = git(root, "diff", "--no-color", "--no-ext-diff", "--unified=0", rev, "--")
+	}
+	if err != nil {
+		return nil, err
+	}
+	changed, err := parseDiff(bytes.NewReader(diff), root)
+	if err != nil || file != "" {
+		return changed, err
+	}
+	untracked, err := git(root, "ls-files", "-z", "--others", "--exclude-standard", "--", "*.go")
+	if err != nil {
+		return nil, err
+	}
+	for name := range strings.SplitSeq(string(untracked), "\x00") {
+		if name == "" {
+			continue
+		}
+		name = filepath.Join(root, filepath.FromSlash(name))
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		lines := make(map[int]bool)
+		for line := range bytes.Count(content, []byte("\n")) + 1 {
+			lines[line+1] = true
+		}
+		changed[name] = lines
+	}
+	return changed, nil
+}
+
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// touches reports whether any line from pos to end was changed.
+func (c changes) touches(act *checker.Action, pos, end token.Pos) bool {
+	start := act.Package.Fset.Position(pos)
+	lines := c[start.Filename]
+	if lines == nil {
+		return false
+	}
+	last := start.Line
+	if end.IsValid() {
+		last = max(last, act.Package.Fset.Position(end).Line)
+	}
+	for line := start.Line; line <= last; line++ {
+		if lines[line] {
+			return true
+		}
+	}
+	return false
+}
+
+// filter keeps the diagnostics of the root actions whose position, or one
+// of whose related positions, lies on a changed line.
+func (c changes) filter(graph *checker.Graph) {
+	for act := range graph.All() {
+		if !act.IsRoot {
+			continue
+		}
+		var kept []analysis.Diagnostic
+		for _, diag := range act.Diagnostics {
+			touched := c.touches(act, diag.Pos, diag.End)
+			for _, rel := range diag.Related {
+				touched = touched || c.touches(act, rel.Pos, rel.End)
+			}
+			if touched {
+				kept = append(kept, diag)
+			}
+		}
+		act.Diagnostics = kept
+	}
+}
@@ -0,0 +1,360 @@
+// Package driver runs a set of analyzers over package patterns, either
+// standalone or as a go vet tool, for the smgt commands.
+package driver
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ribice/smgt/internal/config"
+	"github.com/ribice/smgt/internal/fixcheck"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/analysis/unitchecker"
+	"golang.org/x/tools/go/packages"
+)
+
+// Exit codes follow the x/tools drivers: 1 for errors, 3 for findings.
+const (
+	exitError       = 1
+	exitDiagnostics = 3
+)
+
+// Main runs the analyzers over the packages named on the command line and
+// exits. When invoked by go vet -vettool it hands over to unitchecker.
+func Main(analyzers ...*analysis.Analyzer) {
+	progname := filepath.Base(os.Args[0])
+	log.SetFlags(0)
+	log.SetPrefix(progname + ": ")
+
+	if vetInvocation(os.Args[1:]) {
+		unitchecker.Main(analyzers...)
+	}
+	os.Exit(run(progname, "", os.Args[1:], analyzers, os.Stdout, os.Stderr))
+}
+
+// vetInvocation reports whether go vet is driving the tool: it first asks
+// for -V=full and -flags, then passes a single .cfg file per package.
+func vetInvocation(args []string) bool {
+	for _, arg := range args {
+		name := strings.TrimLeft(arg, "-")
+		if arg != name && (name == "flags" || strings.HasPrefix(name, "V=")) {
+			return true
+		}
+	}
+	return len(args) > 0 && strings.HasSuffix(args[len(args)-1], ".cfg")
+}
+
+type options struct {
+	enable  nameList
+	disable nameList
+	list    bool
+	format  string
+	fix     bool
+	tests   bool
+
+	baseline      string
+	writeBaseline bool
+
+	diff  string
+	since string
+}
+
+// nameList is a flag.Value holding a comma-separated list of analyzer
+// names.
+type nameList []string
+
+func (l nameList) String() string { return strings.Join(l, ",") }
+
+func (l *nameList) Set(value string) error {
+	*l = nil
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*l = append(*l, name)
+		}
+	}
+	return nil
+}
+
+func run(progname, dir string, args []string, analyzers []*analysis.Analyzer, stdout, stderr io.Writer) int {
+	analyzers = sortedByName(analyzers)
+	opts := options{tests: true}
+	fs := flag.NewFlagSet(progname, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Var(&opts.enable, "enable", "comma-separated analyzers to run; empty runs all")
+	fs.Var(&opts.disable, "disable", "comma-separated analyzers to skip")
+	fs.BoolVar(&opts.list, "list", false, "print the analyzers with their documentation and flags, then exit")
+	fs.StringVar(&opts.format, "format", "text", "output format: "+strings.Join(formatNames(), ", "))
+	fs.BoolFunc("json", "print diagnostics as JSON; short for -format=json", formatAlias(&opts.format, "json"))
+	fs.BoolFunc("sarif", "print diagnostics as SARIF 2.1.0; short for -format=sarif", formatAlias(&opts.format, "sarif"))
+	fs.BoolVar(&opts.fix, "fix", false, "apply the suggested fixes")
+	fs.BoolVar(&opts.tests, "test", true, "also analyze test files")
+	fs.StringVar(&opts.baseline, "baseline", "", "suppress the findings recorded in this baseline file and report only new ones")
+	fs.BoolVar(&opts.writeBaseline, "write-baseline", false, "record the current findings in the -baseline file instead of reporting them")
+	fs.StringVar(&opts.diff, "diff", "", "report only findings on lines changed by this unified diff file")
+	fs.StringVar(&opts.since, "since", "", "report only findings on lines changed since this git revision, including uncommitted changes")
+	registerAnalyzerFlags(fs, analyzers)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "%s runs the smgt analyzers.\n\nUsage: %s [flags] packages...\n\nFlags:\n", progname, progname)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return exitError
+	}
+
+	if opts.list {
+		printList(stdout, analyzers)
+		return 0
+	}
+	selected, err := selectAnalyzers(analyzers, opts.enable, opts.disable)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", progname, err)
+		return exitError
+	}
+	// Selecting analyzers on the command line overrides the config files.
+	// The analyzers they require run too, so that the directive check
+	// sees what they suppressed.
+	var names []string
+	if len(opts.enable) > 0 || len(opts.disable) > 0 {
+		for _, a := range selected {
+			names = append(names, a.Name)
+			for _, req := range a.Requires {
+				names = append(names, req.Name)
+			}
+		}
+	}
+	config.SetEnabled(names)
+	config.SetFlags(fs)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+	rep, ok := reporters[opts.format]
+	if !ok {
+		fmt.Fprintf(stderr, "%s: unknown format %q; known formats are %s\n", progname, opts.format, strings.Join(formatNames(), ", "))
+		return exitError
+	}
+	if opts.writeBaseline && opts.baseline == "" {
+		fmt.Fprintf(stderr, "%s: -write-baseline needs -baseline\n", progname)
+		return exitError
+	}
+	if opts.diff != "" && opts.since != "" {
+		fmt.Fprintf(stderr, "%s: -diff and -since are mutually exclusive\n", progname)
+		return exitError
+	}
+	var changed changes
+	if opts.diff != "" || opts.since != "" {
+		if changed, err = readDiff(dir, opts.diff, opts.since); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", progname, err)
+			return exitError
+		}
+	}
+	var known *baseline
+	if opts.baseline != "" && !opts.writeBaseline {
+		if known, err = readBaseline(opts.baseline); err != nil {
+			fmt.Fprintf(stderr, "%s: %v; create it with -write-baseline\n", progname, err)
+			return exitError
+		}
+	}
+
+	cfg := &packages.Config{
+		Mode:  packages.LoadAllSyntax,
+		Dir:   dir,
+		Tests: opts.tests,
+	}
+	pkgs, err := packages.Load(cfg, fs.Args()...)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", progname, err)
+		return exitError
+	}
+	if n := packages.PrintErrors(pkgs); n > 0 {
+		return exitError
+	}
+	graph, err := checker.Analyze(selected, pkgs, nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", progname, err)
+		return exitError
+	}
+
+	if opts.writeBaseline {
+		// A failed analysis would record an incomplete baseline.
+		for act := range graph.All() {
+			if act.Err != nil {
+				fmt.Fprintf(stderr, "%s: %s: %v\n", progname, act.Analyzer.Name, act.Err)
+				return exitError
+			}
+		}
+		n, err := writeBaseline(opts.baseline, graph)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", progname, err)
+			return exitError
+		}
+		fmt.Fprintf(stderr, "%s: recorded %d findings in %s\n", progname, n, opts.baseline)
+		return 0
+	}
+	if known != nil {
+		known.filter(graph, filepath.Dir(opts.baseline))
+	}
+	if changed != nil {
+		changed.filter(graph)
+	}
+
+	root := dir
+	if root == "" {
+		root, _ = os.Getwd()
+	}
+	r := newReport(graph, selected, root)
+	out := stdout
+	if rep.stderr {
+		out = stderr
+	}
+	if err := rep.write(out, r); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", progname, err)
+		return exitError
+	}
+	if !rep.errors {
+		for _, err := range r.errors() {
+			fmt.Fprintln(stderr, err)
+		}
+	}
+	if opts.fix {
+		if err := applyFixes(stderr, graph); err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", progname, err)
+			return exitError
+		}
+	}
+
+	code := 0
+	for act := range graph.All() {
+		if act.Err != nil {
+			return exitError
+		}
+		if act.IsRoot && failing(act) && rep.fails {
+			code = exitDiagnostics
+		}
+	}
+	return code
+}
+
+// failing reports whether any diagnostic of act has a severity above info
+// in the configuration of its file.
+func failing(act *checker.Action) bool {
+	for _, diag := range act.Diagnostics {
+		name := act.Package.Fset.Position(diag.Pos).Filename
+		cfg, err := config.ForDir(filepath.Dir(name))
+		if err != nil || cfg.SeverityOf(act.Analyzer.Name, diag.Category) != "info" {
+			return true
+		}
+	}
+	return false
+}
+
+// formatAlias returns a boolean flag that selects format.
+func formatAlias(dst *string, format string) func(string) error {
+	return func(value string) error {
+		on, err := strconv.ParseBool(value)
+		if err == nil && on {
+			*dst = format
+		}
+		return err
+	}
+}
+
+func sortedByName(analyzers []*analysis.Analyzer) []*analysis.Analyzer {
+	sorted := append([]*analysis.Analyzer(nil), analyzers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// registerAnalyzerFlags exposes each analyzer flag as -name.flag, as the
+// x/tools multichecker does.
+func registerAnalyzerFlags(fs *flag.FlagSet, analyzers []*analysis.Analyzer) {
+	for _, a := range analyzers {
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			fs.Var(f.Value, a.Name+"."+f.Name, f.Usage)
+		})
+	}
+}
+
+func selectAnalyzers(analyzers []*analysis.Analyzer, enable, disable nameList) ([]*analysis.Analyzer, error) {
+	byName := make(map[string]*analysis.Analyzer)
+	for _, a := range analyzers {
+		byName[a.Name] = a
+	}
+	for _, name := range append(append(nameList(nil), enable...), disable...) {
+		if byName[name] == nil {
+			return nil, fmt.Errorf("unknown analyzer %q", name)
+		}
+	}
+	skip := make(map[string]bool)
+	for _, name := range disable {
+		skip[name] = true
+	}
+	if len(enable) > 0 {
+		for _, a := range analyzers {
+			skip[a.Name] = skip[a.Name] || !slices.Contains(enable, a.Name)
+		}
+	}
+	var selected []*analysis.Analyzer
+	for _, a := range analyzers {
+		if !skip[a.Name] {
+			selected = append(selected, a)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no analyzers enabled")
+	}
+	return selected, nil
+}
+
+func printList(w io.Writer, analyzers []*analysis.Analyzer) {
+	for i, a := range analyzers {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\n", a.Name)
+		for _, line := range strings.Split(strings.TrimSpace(a.Doc), "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+		if a.URL != "" {
+			fmt.Fprintf(w, "    %s\n", a.URL)
+		}
+		a.Flags.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(w, "    -%s.%s: %s\n", a.Name, f.Name, f.Usage)
+		})
+	}
+}
+
+// applyFixes applies the first suggested fix of every root diagnostic.
+// Fixes that conflict with another or break the build of a package are
+// left out and reported on stderr.
+func applyFixes(stderr io.Writer, graph *checker.Graph) error {
+	res, err := fixcheck.Verify(slices.Collect(graph.All()))
+	if err != nil {
+		return err
+	}
+	for _, d := range res.Dropped {
+		fmt.Fprintf(stderr, "%s: fix not applied: %s\n", d.Fix, d.Reason)
+	}
+	for _, file := range slices.Sorted(maps.Keys(res.Files)) {
+		if err := os.WriteFile(file, res.Files[file], 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,819 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ribice/smgt/internal/directivecheck"
+	"github.com/ribice/smgt/loopnow"
+	"github.com/ribice/smgt/rot"
+	"github.com/ribice/smgt/set"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// analyzers returns the analyzers of cmd/smgt.
+func analyzers() []*analysis.Analyzer {
+	all := []*analysis.Analyzer{rot.NewAnalyzer(), set.NewAnalyzer(), loopnow.NewAnalyzer()}
+	return append(all, directivecheck.NewAnalyzer(all...))
+}
+
+func runFixture(t *testing.T, dir string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run("smgt", dir, args, analyzers(), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func fixtureDir(t *testing.T) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+	return filepath.Join(wd, "testdata", "fixture")
+}
+
+func TestVetInvocation(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"-V=full"}, true},
+		{[]string{"-flags"}, true},
+		{[]string{"-set.keys=string", "/tmp/go-build/vet.cfg"}, true},
+		{[]string{"./..."}, false},
+		{[]string{"-enable=set", "./..."}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := vetInvocation(tt.args); got != tt.want {
+			t.Errorf("vetInvocation(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	code, stdout, _ := runFixture(t, "", "-list")
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0", code)
+	}
+	for _, want := range []string{"loopnow\n", "rot\n", "set\n", "-set.keys:", "-loopnow.callbacks:", "set/README.md#set-analyzer"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("-list output does not contain %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestEnableDisable(t *testing.T) {
+	dir := fixtureDir(t)
+	const setMsg, loopMsg = "is used as a set", "time.Now should not be called inside loops"
+
+	code, _, stderr := runFixture(t, dir, "-enable=set", ".")
+	if code != exitDiagnostics {
+		t.Fatalf("exit code = %d, want %d; stderr:\n%s", code, exitDiagnostics, stderr)
+	}
+	if !strings.Contains(stderr, setMsg) || strings.Contains(stderr, loopMsg) {
+		t.Errorf("-enable=set reported:\n%s", stderr)
+	}
+
+	_, _, stderr = runFixture(t, dir, "-disable=set", ".")
+	if strings.Contains(stderr, setMsg) || !strings.Contains(stderr, loopMsg) {
+		t.Errorf("-disable=set reported:\n%s", stderr)
+	}
+
+	if code, _, _ := runFixture(t, dir, "-enable=nope", "."); code != exitError {
+		t.Errorf("unknown analyzer: exit code = %d, want %d", code, exitError)
+	}
+	if code, _, _ := runFixture(t, dir, "-enable=set", "-disable=set", "."); code != exitError {
+		t.Errorf("nothing enabled: exit code = %d, want %d", code, exitError)
+	}
+}
+
+func TestAnalyzerFlags(t *testing.T) {
+	_, _, stderr := runFixture(t, fixtureDir(t), "-enable=set", "-set.keys=int", ".")
+	if strings.Contains(stderr, "is used as a set") {
+		t.Errorf("-set.keys=int still reported string keys:\n%s", stderr)
+	}
+}
+
+// copyFixture copies the fixture module into a temporary directory.
+func copyFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"go.mod", "fixture.go"} {
+		content, err := os.ReadFile(filepath.Join(fixtureDir(t), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestConfigFile(t *testing.T) {
+	dir := copyFixture(t)
+	config := `{"disable": ["loopnow"], "severity": {"set": "info"}}`
+	if err := os.WriteFile(filepath.Join(dir, ".smgt.json"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runFixture(t, dir, ".")
+	if code != 0 {
+		t.Errorf("info findings only: exit code = %d, want 0; stderr:\n%s", code, stderr)
+	}
+	if !strings.Contains(stderr, "is used as a set") || strings.Contains(stderr, "time.Now") {
+		t.Errorf("config file did not select set alone:\n%s", stderr)
+	}
+
+	code, _, stderr = runFixture(t, dir, "-enable=loopnow", ".")
+	if code != exitDiagnostics || !strings.Contains(stderr, "time.Now") {
+		t.Errorf("-enable=loopnow did not override the config file: exit code %d, stderr:\n%s", code, stderr)
+	}
+}
+
+func TestFix(t *testing.T) {
+	dir := copyFixture(t)
+
+	runFixture(t, dir, "-enable=set,loopnow", "-fix", ".")
+	got, err := os.ReadFile(filepath.Join(dir, "fixture.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"s := map[string]struct{}{}", "s[k] = struct{}{}", "now := time.Now()"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("fixed file does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	dir := copyFixture(t)
+	baseline := filepath.Join(dir, "smgt-baseline.json")
+
+	code, _, stderr := runFixture(t, dir, "-baseline="+baseline, "-write-baseline", ".")
+	if code != 0 || !strings.Contains(stderr, "recorded 2 findings") {
+		t.Fatalf("-write-baseline: exit code = %d; stderr:\n%s", code, stderr)
+	}
+	content, err := os.ReadFile(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"file": "fixture.go"`, `"func": "seen"`, `"func": "stamps"`} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("baseline does not contain %s:\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), `"line"`) {
+		t.Errorf("baseline records line numbers:\n%s", content)
+	}
+
+	code, _, stderr = runFixture(t, dir, "-baseline="+baseline, ".")
+	if code != 0 || stderr != "" {
+		t.Errorf("baselined run: exit code = %d; stderr:\n%s", code, stderr)
+	}
+
+	// Shift every line and add a new finding: only the new one is reported.
+	source := filepath.Join(dir, "fixture.go")
+	old, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shifted := strings.Replace(string(old), "import \"time\"\n", "import \"time\"\n\n// unrelated edit\n\n", 1) + `
+func more(keys []int) int {
+	m := map[int]bool{}
+	for _, k := range keys {
+		m[k] = true
+	}
+	return len(m)
+}
+`
+	if err := os.WriteFile(source, []byte(shifted), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runFixture(t, dir, "-baseline="+baseline, ".")
+	if code != exitDiagnostics || strings.Count(stderr, "\n") != 1 || !strings.Contains(stderr, "map[int]bool variable m") {
+		t.Errorf("after edits: exit code = %d; stderr:\n%s", code, stderr)
+	}
+
+	// Regenerating drops findings that no longer occur.
+	fixed := strings.Replace(shifted, "out = append(out, time.Now())", "out = append(out, time.Time{})", 1)
+	if err := os.WriteFile(source, []byte(fixed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runFixture(t, dir, "-baseline="+baseline, "-write-baseline", ".")
+	content, err = os.ReadFile(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), `"func": "stamps"`) || !strings.Contains(string(content), `"func": "more"`) {
+		t.Errorf("regenerated baseline:\n%s", content)
+	}
+
+	if code, _, _ := runFixture(t, dir, "-baseline="+filepath.Join(dir, "missing.json"), "."); code != exitError {
+		t.Errorf("missing baseline: exit code = %d, want %d", code, exitError)
+	}
+}
+
+func TestNormalizeMessage(t *testing.T) {
+	got := normalizeMessage("saves about 24  bytes;\tdeclared at line 12")
+	if want := "saves about N bytes; declared at line N"; got != want {
+		t.Errorf("normalizeMessage = %q, want %q", got, want)
+	}
+}
+
+func gitRun(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-c", "commit.gpgsign=false"}, args...)
+	if _, err := git(dir, args...); err != nil {
+		t.Fatalf("Failed to run git: %s", err)
+	}
+}
+
+func TestSince(t *testing.T) {
+	dir := copyFixture(t)
+	source := filepath.Join(dir, "fixture.go")
+	old, err := os.ReadFile(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := string(old) + `
+func early(n int) {
+	total := n * 2
+	println("unrelated")
+	println("unrelated")
+	println(total)
+}
+
+func late(n int) {
+	total := n * 2
+	println("unrelated")
+	println("unrelated")
+	println(total)
+}
+
+func untouched(n int) {
+	total := n * 2
+	println("unrelated")
+	println("unrelated")
+	println(total)
+}
+`
+	if err := os.WriteFile(source, []byte(base), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitRun(t, dir, "init", "-q")
+	gitRun(t, dir, "add", ".")
+	gitRun(t, dir, "commit", "-q", "-m", "base")
+
+	code, _, stderr := runFixture(t, dir, "-since=HEAD", ".")
+	if code != 0 || stderr != "" {
+		t.Errorf("unchanged tree: exit code = %d; stderr:\n%s", code, stderr)
+	}
+
+	// Touch the declaration in early, the first use in late, and add a
+	// new set; the findings in seen and stamps stay untouched.
+	edited := strings.Replace(base, "total := n * 2\n", "total := n * 3\n", 1)
+	late := strings.Index(edited, "func late")
+	late += strings.Index(edited[late:], "println(total)")
+	edited = edited[:late] + "println(total, n)" + edited[late+len("println(total)"):]
+	edited += `
+func more(keys []int) int {
+	m := map[int]bool{}
+	for _, k := range keys {
+		m[k] = true
+	}
+	return len(m)
+}
+`
+	if err := os.WriteFile(source, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runFixture(t, dir, "-since=HEAD", ".")
+	if code != exitDiagnostics {
+		t.Errorf("exit code = %d, want %d", code, exitDiagnostics)
+	}
+	if strings.Count(stderr, "variable total should be declared") != 2 || strings.Count(stderr, "is used as a set") != 1 || strings.Contains(stderr, "time.Now") {
+		t.Errorf("-since=HEAD should report both rot findings and the new set only:\n%s", stderr)
+	}
+
+	diff, err := git(dir, "diff", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := filepath.Join(t.TempDir(), "change.diff")
+	if err := os.WriteFile(patch, diff, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, fromFile := runFixture(t, dir, "-diff="+patch, ".")
+	if fromFile != stderr {
+		t.Errorf("-diff reported\n%s\nbut -since reported\n%s", fromFile, stderr)
+	}
+
+	// git diff leaves out a file that was never added.
+	added := `package fixture
+
+func added(keys []string) int {
+	m := map[string]bool{}
+	for _, k := range keys {
+		m[k] = true
+	}
+	return len(m)
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "added.go"), []byte(added), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, stderr = runFixture(t, dir, "-since=HEAD", ".")
+	if !strings.Contains(stderr, "added.go:") || strings.Count(stderr, "is used as a set") != 2 {
+		t.Errorf("-since=HEAD should report the untracked file:\n%s", stderr)
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	diff := `diff --git a/x.go b/x.go
+--- a/x.go
++++ b/x.go
+@@ -3,0 +4,2 @@ func f() {
++	a := 1
++	b := 2
+@@ -10 +11,0 @@ func g() {
+-	c := 3
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package gone
+`
+	got, err := parseDiff(strings.NewReader(diff), "/repo")
+	if err != nil {
+		t.Fatalf("Failed to parse diff: %s", err)
+	}
+	lines := got[filepath.Join("/repo", "x.go")]
+	for _, line := range []int{4, 5, 12} {
+		if !lines[line] {
+			t.Errorf("line %d not marked as changed: %v", line, lines)
+		}
+	}
+	if len(lines) != 3 || len(got) != 1 {
+		t.Errorf("parseDiff = %v", got)
+	}
+}
+
+// TestParseDiffNoPrefix checks that paths of a diff made with
+// --no-prefix keep a leading b/ directory.
+func TestParseDiffNoPrefix(t *testing.T) {
+	diff := `--- b/x.go
++++ b/x.go
+@@ -1 +1 @@
+-package x
++package y
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1 @@
++package x
+`
+	got, err := parseDiff(strings.NewReader(diff), "/repo")
+	if err != nil {
+		t.Fatalf("Failed to parse diff: %s", err)
+	}
+	want := changes{
+		filepath.Join("/repo", "b", "x.go"): {1: true},
+		filepath.Join("/repo", "new.go"):    {1: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiff = %v, want %v", got, want)
+	}
+}
+
+// TestParseDiffHeaderLikeLines checks that removed and added lines that
+// look like file headers stay part of their hunk.
+func TestParseDiffHeaderLikeLines(t *testing.T) {
+	diff := `--- a/x.go
++++ b/x.go
+@@ -2,3 +2,3 @@
+ // Usage:
+--- old flag
++++ new flag
+ func f() {}
+@@ -9,0 +10 @@
++// added
+`
+	got, err := parseDiff(strings.NewReader(diff), "/repo")
+	if err != nil {
+		t.Fatalf("Failed to parse diff: %s", err)
+	}
+	want := changes{filepath.Join("/repo", "x.go"): {3: true, 10: true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDiff = %v, want %v", got, want)
+	}
+}
+
+// reportPackages are the analysistest packages that the report goldens
+// are generated from, so that they follow the analyzers' real messages,
+// categories and related positions.
+var reportPackages = []string{
+	"rot/testdata/src/rotcategory",
+	"loopnow/testdata/src/loopnowcategory",
+	"set/testdata/src/setfix",
+	"internal/directivecheck/testdata/src/directives",
+}
+
+// reportDir copies reportPackages into a module in a temporary directory.
+// Its configuration makes rot findings info.
+func reportDir(t *testing.T) string {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+	root := filepath.Join(wd, "..", "..")
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module report\n\ngo 1.24\n",
+		".smgt.json": `{"severity": {"rot": "info"}}` + "\n",
+	}
+	for _, pkg := range reportPackages {
+		src := filepath.Join(root, filepath.FromSlash(pkg))
+		names, err := filepath.Glob(filepath.Join(src, "*.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range names {
+			content, err := os.ReadFile(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[filepath.Join(filepath.Base(src), filepath.Base(name))] = string(content)
+		}
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// golden compares got with the named golden file in testdata, after
+// replacing the absolute fixture directory, or rewrites it with -update.
+func golden(t *testing.T, name, got, dir string) {
+	t.Helper()
+	got = strings.ReplaceAll(got, dir, "/SRCROOT")
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s; rerun with -update and review the diff:\n%s", name, got)
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	dir := reportDir(t)
+	code, stdout, stderr := runFixture(t, dir, "-sarif", "./...")
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0; stderr:\n%s", code, stderr)
+	}
+	golden(t, "report.sarif.golden", stdout, dir)
+
+	// Check the properties SARIF 2.1.0 requires on a generic decode, so
+	// that a missing or misspelled key fails rather than matching the
+	// reporter's own types.
+	var log map[string]any
+	if err := json.Unmarshal([]byte(stdout), &log); err != nil {
+		t.Fatalf("Failed to decode SARIF: %s", err)
+	}
+	if version := str(t, log, "version"); version != "2.1.0" {
+		t.Errorf("version = %q, want 2.1.0", version)
+	}
+	if schema := str(t, log, "$schema"); !strings.Contains(schema, "2.1.0") {
+		t.Errorf("$schema = %q", schema)
+	}
+	runs := array(t, log, "runs")
+	if len(runs) != 1 {
+		t.Fatalf("got %d runs, want 1", len(runs))
+	}
+	run := runs[0].(map[string]any)
+	driver := object(t, object(t, run, "tool"), "driver")
+	if str(t, driver, "name") == "" {
+		t.Error("tool.driver.name is empty")
+	}
+	rules := array(t, driver, "rules")
+	ruleIDs := make(map[string]int)
+	for i, rule := range rules {
+		id := str(t, rule, "id")
+		if id == "" {
+			t.Errorf("rule %d has no id", i)
+		}
+		ruleIDs[id] = i
+	}
+
+	results := array(t, run, "results")
+	fingerprints := make(map[string]bool)
+	for _, r := range results {
+		ruleID := str(t, r, "ruleId")
+		i, ok := ruleIDs[ruleID]
+		if !ok {
+			t.Errorf("result ruleId %q names no rule", ruleID)
+		}
+		if index, ok := r.(map[string]any)["ruleIndex"]; ok && index != float64(i) {
+			t.Errorf("result %q has ruleIndex %v, want %d", ruleID, index, i)
+		}
+		if str(t, object(t, r, "message"), "text") == "" {
+			t.Errorf("result %q has no message", ruleID)
+		}
+		switch level := str(t, r, "level"); level {
+		case "none", "note", "warning", "error":
+		default:
+			t.Errorf("result %q has level %q", ruleID, level)
+		}
+		fp := str(t, object(t, r, "partialFingerprints"), "smgt/v1")
+		if fp == "" || fingerprints[fp] {
+			t.Errorf("result %q has fingerprint %q, which is empty or repeated", ruleID, fp)
+		}
+		fingerprints[fp] = true
+
+		locations := array(t, r, "locations")
+		if len(locations) == 0 {
+			t.Errorf("result %q has no location", ruleID)
+		}
+		if related, ok := r.(map[string]any)["relatedLocations"].([]any); ok {
+			locations = append(locations, related...)
+		}
+		for _, loc := range locations {
+			physical := object(t, loc, "physicalLocation")
+			if str(t, object(t, physical, "artifactLocation"), "uri") == "" {
+				t.Errorf("result %q has a location without a uri", ruleID)
+			}
+			region := object(t, physical, "region")
+			if line := number(t, region, "startLine"); line < 1 {
+				t.Errorf("result %q has startLine %v", ruleID, line)
+			}
+			if column := number(t, region, "startColumn"); column < 1 {
+				t.Errorf("result %q has startColumn %v", ruleID, column)
+			}
+		}
+		fixes, _ := r.(map[string]any)["fixes"].([]any)
+		for _, fix := range fixes {
+			changes := array(t, fix, "artifactChanges")
+			if len(changes) == 0 {
+				t.Errorf("result %q has a fix without changes", ruleID)
+			}
+			for _, change := range changes {
+				if len(array(t, change, "replacements")) == 0 {
+					t.Errorf("result %q has a change without replacements", ruleID)
+				}
+			}
+		}
+	}
+	if want := findingCount(t, dir); len(results) != want {
+		t.Errorf("got %d results, want %d", len(results), want)
+	}
+}
+
+// object returns the JSON object under key in v, which must be an object.
+func object(t *testing.T, v any, key string) map[string]any {
+	t.Helper()
+	o, ok := member(t, v, key).(map[string]any)
+	if !ok {
+		t.Fatalf("%q is not an object", key)
+	}
+	return o
+}
+
+// array returns the JSON array under key in v, which must be an object.
+func array(t *testing.T, v any, key string) []any {
+	t.Helper()
+	a, ok := member(t, v, key).([]any)
+	if !ok {
+		t.Fatalf("%q is not an array", key)
+	}
+	return a
+}
+
+// str returns the JSON string under key in v, which must be an object.
+func str(t *testing.T, v any, key string) string {
+	t.Helper()
+	s, ok := member(t, v, key).(string)
+	if !ok {
+		t.Fatalf("%q is not a string", key)
+	}
+	return s
+}
+
+// number returns the JSON number under key in v, which must be an object.
+func number(t *testing.T, v any, key string) float64 {
+	t.Helper()
+	n, ok := member(t, v, key).(float64)
+	if !ok {
+		t.Fatalf("%q is not a number", key)
+	}
+	return n
+}
+
+func member(t *testing.T, v any, key string) any {
+	t.Helper()
+	o, ok := v.(map[string]any)
+	if !ok {
+		t.Fatalf("looking up %q in %T, not an object", key, v)
+	}
+	m, ok := o[key]
+	if !ok {
+		t.Fatalf("missing key %q in %v", key, o)
+	}
+	return m
+}
+
+// findingCount returns the number of findings in the text report of dir,
+// not counting related positions.
+func findingCount(t *testing.T, dir string) int {
+	t.Helper()
+	_, _, stderr := runFixture(t, dir, "./...")
+	n := 0
+	for _, line := range strings.Split(strings.TrimSpace(stderr), "\n") {
+		if !strings.Contains(line, ": \t") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestFormats(t *testing.T) {
+	dir := reportDir(t)
+	for _, format := range formatNames() {
+		t.Run(format, func(t *testing.T) {
+			code, stdout, stderr := runFixture(t, dir, "-format="+format, "./...")
+			out := stdout
+			if format == "text" {
+				out = stderr
+				if code != exitDiagnostics {
+					t.Errorf("exit code = %d, want %d", code, exitDiagnostics)
+				}
+			} else if code != 0 || stderr != "" {
+				t.Errorf("exit code = %d, want 0; stderr:\n%s", code, stderr)
+			}
+			golden(t, "report."+format+".golden", out, dir)
+		})
+	}
+
+	if code, _, stderr := runFixture(t, dir, "-format=html", "./..."); code != exitError || !strings.Contains(stderr, "known formats are checkstyle, gitlab, json, junit, sarif, text") {
+		t.Errorf("unknown format: exit code = %d; stderr:\n%s", code, stderr)
+	}
+}
+
+func TestCheckstyle(t *testing.T) {
+	dir := reportDir(t)
+	_, stdout, _ := runFixture(t, dir, "-format=checkstyle", "./...")
+	var log checkstyleLog
+	if err := xml.Unmarshal([]byte(stdout), &log); err != nil {
+		t.Fatalf("Failed to decode Checkstyle XML: %s", err)
+	}
+	if len(log.Files) != 6 {
+		t.Fatalf("got %d files, want one per file with findings: %+v", len(log.Files), log.Files)
+	}
+	errors := 0
+	for _, file := range log.Files {
+		errors += len(file.Errors)
+		if file.Name != "rotcategory/category.go" {
+			continue
+		}
+		for _, got := range file.Errors {
+			if !strings.HasPrefix(got.Source, "smgt.rot.") || got.Severity != "info" {
+				t.Errorf("rot error = %+v, want a rot source with the configured info severity", got)
+			}
+		}
+	}
+	if want := findingCount(t, dir); errors != want {
+		t.Errorf("got %d errors, want %d", errors, want)
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	_, stdout, _ := runFixture(t, reportDir(t), "-format=junit", "-enable=rot", "./rotcategory")
+	var suites junitSuites
+	if err := xml.Unmarshal([]byte(stdout), &suites); err != nil {
+		t.Fatalf("Failed to decode JUnit XML: %s", err)
+	}
+	if suites.Tests != 3 || suites.Failures != 3 || len(suites.Suites) != 1 {
+		t.Fatalf("got %d tests and %d failures in %d suites, want 3, 3 and 1", suites.Tests, suites.Failures, len(suites.Suites))
+	}
+	if rot := suites.Suites[0]; rot.Name != "rot" || !strings.Contains(rot.Cases[0].Failure.Text, "first used here") {
+		t.Errorf("rot suite does not include the related position: %+v", rot)
+	}
+
+	_, stdout, _ = runFixture(t, fixtureDir(t), "-format=junit", "-enable=rot", ".")
+	if err := xml.Unmarshal([]byte(stdout), &suites); err != nil {
+		t.Fatalf("Failed to decode JUnit XML: %s", err)
+	}
+	if suites.Tests != 1 || suites.Failures != 0 {
+		t.Errorf("an analyzer without findings should pass one case, got %d tests and %d failures", suites.Tests, suites.Failures)
+	}
+}
+
+func TestGitLab(t *testing.T) {
+	dir := reportDir(t)
+	_, stdout, _ := runFixture(t, dir, "-format=gitlab", "./...")
+	// Check the keys GitLab reads on a generic decode, so that a missing
+	// or misspelled key fails rather than matching the reporter's types.
+	var issues []any
+	if err := json.Unmarshal([]byte(stdout), &issues); err != nil {
+		t.Fatalf("Failed to decode GitLab report: %s", err)
+	}
+	if want := findingCount(t, dir); len(issues) != want {
+		t.Fatalf("got %d issues, want %d", len(issues), want)
+	}
+	fingerprints := make(map[string]bool)
+	for _, issue := range issues {
+		for _, key := range []string{"description", "check_name", "fingerprint", "severity"} {
+			if str(t, issue, key) == "" {
+				t.Errorf("issue %v has an empty %s", issue, key)
+			}
+		}
+		checkName, severity := str(t, issue, "check_name"), str(t, issue, "severity")
+		switch severity {
+		case "info", "minor", "major", "critical", "blocker":
+		default:
+			t.Errorf("issue %s has severity %q", checkName, severity)
+		}
+		if strings.HasPrefix(checkName, "rot/") && severity != "info" {
+			t.Errorf("issue %s has severity %q, want the configured info", checkName, severity)
+		}
+		fp := str(t, issue, "fingerprint")
+		if fingerprints[fp] {
+			t.Errorf("issue %s repeats fingerprint %q", checkName, fp)
+		}
+		fingerprints[fp] = true
+
+		location := object(t, issue, "location")
+		if str(t, location, "path") == "" {
+			t.Errorf("issue %s has no path", checkName)
+		}
+		if begin := number(t, object(t, location, "lines"), "begin"); begin < 1 {
+			t.Errorf("issue %s begins at line %v", checkName, begin)
+		}
+	}
+}
+
+// BenchmarkAnalyzers runs every analyzer over this module, alone and
+// together, as smgt does. "separate" runs rot, set and loopnow one after
+// another, each loading its own inspector, to compare with "all", where
+// they share one; directive alone already runs all three.
+func BenchmarkAnalyzers(b *testing.B) {
+	wd, err := os.Getwd()
+	if err != nil {
+		b.Fatalf("Failed to get wd: %s", err)
+	}
+	cfg := &packages.Config{Mode: packages.LoadAllSyntax, Dir: filepath.Join(wd, "..", ".."), Tests: true}
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		b.Fatalf("Failed to load packages: %s", err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		b.Fatal("packages contain errors")
+	}
+
+	run := func(b *testing.B, analyzers []*analysis.Analyzer) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := checker.Analyze(analyzers, pkgs, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	for _, a := range analyzers() {
+		b.Run(a.Name, func(b *testing.B) { run(b, []*analysis.Analyzer{a}) })
+	}
+	b.Run("all", func(b *testing.B) { run(b, analyzers()) })
+	b.Run("separate", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			for _, a := range []*analysis.Analyzer{rot.NewAnalyzer(), set.NewAnalyzer(), loopnow.NewAnalyzer()} {
+				if _, err := checker.Analyze([]*analysis.Analyzer{a}, pkgs, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
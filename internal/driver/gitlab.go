@@ -0,0 +1,58 @@
+package driver
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// gitlabIssue is an entry of a GitLab Code Quality report.
+// https://docs.gitlab.com/ci/testing/code_quality/#code-quality-report-format
+type gitlabIssue struct {
+	Description string         `json:"description"`
+	CheckName   string         `json:"check_name"`
+	Fingerprint string         `json:"fingerprint"`
+	Severity    string         `json:"severity"`
+	Location    gitlabLocation `json:"location"`
+}
+
+type gitlabLocation struct {
+	Path  string      `json:"path"`
+	Lines gitlabLines `json:"lines"`
+}
+
+type gitlabLines struct {
+	Begin int `json:"begin"`
+}
+
+// writeGitLab prints the report as a GitLab Code Quality report. The
+// check name is the analyzer, or analyzer/category when it has one.
+func writeGitLab(w io.Writer, r *report) error {
+	issues := []gitlabIssue{}
+	for _, it := range r.items {
+		posn := it.position()
+		check := it.act.Analyzer.Name
+		if it.diag.Category != "" {
+			check += "/" + it.diag.Category
+		}
+		issues = append(issues, gitlabIssue{
+			Description: it.diag.Message,
+			CheckName:   check,
+			Fingerprint: it.fingerprint,
+			Severity:    gitlabSeverity(it.severity),
+			Location:    gitlabLocation{Path: r.path(posn.Filename), Lines: gitlabLines{Begin: posn.Line}},
+		})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(issues)
+}
+
+func gitlabSeverity(severity string) string {
+	switch severity {
+	case "error":
+		return "major"
+	case "info":
+		return "info"
+	}
+	return "minor"
+}
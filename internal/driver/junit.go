@@ -0,0 +1,92 @@
+package driver
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit prints the report as JUnit XML with a test suite per
+// analyzer and a failing test case per finding. An analyzer without
+// findings gets one passing case, so CI shows that it ran; analysis
+// errors become error cases.
+func writeJUnit(w io.Writer, r *report) error {
+	suites := make(map[string]*junitSuite)
+	var log junitSuites
+	for _, a := range r.analyzers {
+		log.Suites = append(log.Suites, junitSuite{Name: a.Name})
+	}
+	for i := range log.Suites {
+		suites[log.Suites[i].Name] = &log.Suites[i]
+	}
+
+	for _, it := range r.items {
+		posn := it.position()
+		suite := suites[it.act.Analyzer.Name]
+		text := fmt.Sprintf("%s:%d:%d: %s", r.path(posn.Filename), posn.Line, posn.Column, it.diag.Message)
+		for _, rel := range it.diag.Related {
+			relPosn := it.act.Package.Fset.Position(rel.Pos)
+			text += fmt.Sprintf("\n%s:%d:%d: %s", r.path(relPosn.Filename), relPosn.Line, relPosn.Column, rel.Message)
+		}
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      fmt.Sprintf("%s:%d:%d", r.path(posn.Filename), posn.Line, posn.Column),
+			ClassName: it.act.Package.PkgPath,
+			Failure:   &junitFailure{Message: it.diag.Message, Type: it.severity, Text: text},
+		})
+		suite.Failures++
+	}
+	for act := range r.graph.All() {
+		if act.Err == nil {
+			continue
+		}
+		suite := suites[act.Analyzer.Name]
+		if suite == nil {
+			continue
+		}
+		suite.Cases = append(suite.Cases, junitCase{
+			Name:      act.Package.PkgPath,
+			ClassName: act.Package.PkgPath,
+			Error:     &junitFailure{Message: act.Err.Error(), Type: "error", Text: act.Err.Error()},
+		})
+		suite.Errors++
+	}
+
+	for i := range log.Suites {
+		suite := &log.Suites[i]
+		if len(suite.Cases) == 0 {
+			suite.Cases = []junitCase{{Name: "no findings", ClassName: "smgt." + suite.Name}}
+		}
+		suite.Tests = len(suite.Cases)
+		log.Tests += suite.Tests
+		log.Failures += suite.Failures
+	}
+	return writeXML(w, log)
+}
@@ -0,0 +1,138 @@
+package driver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"go/token"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ribice/smgt/internal/config"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+)
+
+// A reporter prints the result of a run in one output format.
+type reporter struct {
+	// write prints the report to w.
+	write func(w io.Writer, r *report) error
+	// stderr selects standard error instead of standard output.
+	stderr bool
+	// fails makes findings set the exit code.
+	fails bool
+	// errors reports whether the output includes analysis errors;
+	// otherwise they are printed to standard error.
+	errors bool
+}
+
+var reporters = map[string]reporter{
+	"text":       {write: writeText, stderr: true, fails: true, errors: true},
+	"json":       {write: writeJSON, errors: true},
+	"sarif":      {write: writeSARIF},
+	"checkstyle": {write: writeCheckstyle},
+	"junit":      {write: writeJUnit},
+	"gitlab":     {write: writeGitLab},
+}
+
+func formatNames() []string {
+	names := make([]string, 0, len(reporters))
+	for name := range reporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A report holds the findings of a run, each reported once even when a
+// file belongs to both a package and its test variant.
+type report struct {
+	graph     *checker.Graph
+	analyzers []*analysis.Analyzer
+	// root is the directory that paths are relative to.
+	root  string
+	items []item
+}
+
+// An item is one finding of a report.
+type item struct {
+	act  *checker.Action
+	diag analysis.Diagnostic
+	key  finding
+	// fingerprint identifies the finding independently of its line.
+	fingerprint string
+	// severity is the configured severity: error, warning or info.
+	severity string
+}
+
+func newReport(graph *checker.Graph, analyzers []*analysis.Analyzer, root string) *report {
+	r := &report{graph: graph, analyzers: analyzers, root: root}
+	for act, keys := range findings(graph, root) {
+		for i, key := range keys {
+			if key.Count != -1 {
+				r.items = append(r.items, item{act: act, diag: act.Diagnostics[i], key: key})
+			}
+		}
+	}
+	sort.Slice(r.items, func(i, j int) bool {
+		x, y := r.items[i].position(), r.items[j].position()
+		if x != y {
+			return x.Filename < y.Filename || x.Filename == y.Filename && x.Offset < y.Offset
+		}
+		if r.items[i].key.Analyzer != r.items[j].key.Analyzer {
+			return r.items[i].key.Analyzer < r.items[j].key.Analyzer
+		}
+		return r.items[i].diag.Message < r.items[j].diag.Message
+	})
+
+	occurrences := make(map[finding]int)
+	for i := range r.items {
+		it := &r.items[i]
+		// Identical keys are told apart by their order in the file.
+		n := occurrences[it.key]
+		occurrences[it.key]++
+		sum := sha256.Sum256([]byte(strings.Join([]string{it.key.Analyzer, it.key.File, it.key.Func, it.key.Message, strconv.Itoa(n)}, "\x00")))
+		it.fingerprint = hex.EncodeToString(sum[:16])
+
+		it.severity = "warning"
+		if cfg, err := config.ForDir(filepath.Dir(it.position().Filename)); err == nil {
+			it.severity = cfg.SeverityOf(it.act.Analyzer.Name, it.diag.Category)
+		}
+	}
+	return r
+}
+
+func (it *item) position() token.Position {
+	return it.act.Package.Fset.Position(it.diag.Pos)
+}
+
+// path returns filename relative to the report root, or unchanged when it
+// lies outside it.
+func (r *report) path(filename string) string {
+	if rel, err := filepath.Rel(r.root, filename); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.ToSlash(filename)
+}
+
+// errors returns the analysis errors of the run.
+func (r *report) errors() []string {
+	var errs []string
+	for act := range r.graph.All() {
+		if act.Err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", act.Analyzer.Name, act.Err))
+		}
+	}
+	return errs
+}
+
+func writeText(w io.Writer, r *report) error {
+	return r.graph.PrintText(w, -1)
+}
+
+func writeJSON(w io.Writer, r *report) error {
+	return r.graph.PrintJSON(w)
+}
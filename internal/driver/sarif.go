@@ -0,0 +1,292 @@
+package driver
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// The types below model the subset of SARIF 2.1.0 that smgt emits.
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	ColumnKind         string                           `json:"columnKind"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+	HelpURI          string       `json:"helpUri,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	RelatedLocations    []sarifLocation   `json:"relatedLocations,omitempty"`
+	Fixes               []sarifFix        `json:"fixes,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	ID               *int                  `json:"id,omitempty"`
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+	Message          *sarifMessage         `json:"message,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion   `json:"deletedRegion"`
+	InsertedContent *sarifMessage `json:"insertedContent,omitempty"`
+}
+
+const srcRoot = "%SRCROOT%"
+
+// sarifWriter converts positions into SARIF locations relative to root.
+type sarifWriter struct {
+	fset  *token.FileSet
+	root  string
+	lines map[string][]string
+}
+
+// writeSARIF prints the report as a SARIF log with one run. Paths are
+// relative to the report root.
+func writeSARIF(w io.Writer, r *report) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "smgt",
+			InformationURI: "https://github.com/ribice/smgt",
+			Rules:          []sarifRule{},
+		}},
+		OriginalURIBaseIDs: map[string]sarifArtifactLocation{
+			srcRoot: {URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(r.root) + "/"}).String()},
+		},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	// Each analyzer is a rule, and so is each category it reported, with
+	// the analyzer/category ID that .smgt.json severities use.
+	categories := make(map[string][]string)
+	for _, it := range r.items {
+		name, category := it.act.Analyzer.Name, it.diag.Category
+		if category != "" && !slices.Contains(categories[name], category) {
+			categories[name] = append(categories[name], category)
+		}
+	}
+	index := make(map[string]int)
+	for _, a := range r.analyzers {
+		short, _, _ := strings.Cut(a.Doc, "\n\n")
+		short = strings.Join(strings.Fields(short), " ")
+		index[a.Name] = len(run.Tool.Driver.Rules)
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:               a.Name,
+			ShortDescription: sarifMessage{short},
+			FullDescription:  sarifMessage{a.Doc},
+			HelpURI:          a.URL,
+		})
+		slices.Sort(categories[a.Name])
+		for _, category := range categories[a.Name] {
+			id := a.Name + "/" + category
+			index[id] = len(run.Tool.Driver.Rules)
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               id,
+				ShortDescription: sarifMessage{short + " Category: " + category + "."},
+				FullDescription:  sarifMessage{a.Doc},
+				HelpURI:          categoryURL(a.URL, category),
+			})
+		}
+	}
+
+	sw := &sarifWriter{root: r.root, lines: make(map[string][]string)}
+	for _, it := range r.items {
+		sw.fset = it.act.Package.Fset
+		id := it.act.Analyzer.Name
+		if it.diag.Category != "" {
+			id += "/" + it.diag.Category
+		}
+		result := sarifResult{
+			RuleID:    id,
+			RuleIndex: index[id],
+			Level:     sarifLevel(it.severity),
+			Message:   sarifMessage{it.diag.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sw.location(it.diag.Pos, it.diag.End)}},
+		}
+		if it.diag.Category != "" {
+			result.Properties = map[string]string{"category": it.diag.Category}
+		}
+		for i, rel := range it.diag.Related {
+			id := i + 1
+			result.RelatedLocations = append(result.RelatedLocations, sarifLocation{
+				ID:               &id,
+				PhysicalLocation: sw.location(rel.Pos, rel.End),
+				Message:          &sarifMessage{rel.Message},
+			})
+		}
+		for _, fix := range it.diag.SuggestedFixes {
+			result.Fixes = append(result.Fixes, sw.fix(fix))
+		}
+		result.PartialFingerprints = map[string]string{"smgt/v1": it.fingerprint}
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// categoryURL links to the section of the analyzer's documentation for
+// the category, which go/analysis places at the fragment of the same name.
+func categoryURL(analyzerURL, category string) string {
+	if analyzerURL == "" {
+		return ""
+	}
+	base, _, _ := strings.Cut(analyzerURL, "#")
+	return base + "#" + category
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case "error":
+		return "error"
+	case "info":
+		return "note"
+	}
+	return "warning"
+}
+
+func (sw *sarifWriter) location(pos, end token.Pos) sarifPhysicalLocation {
+	start := sw.fset.Position(pos)
+	loc := sarifPhysicalLocation{
+		ArtifactLocation: sw.artifact(start.Filename),
+		Region:           sarifRegion{StartLine: start.Line, StartColumn: sw.column(start)},
+	}
+	if end.IsValid() && end > pos {
+		stop := sw.fset.Position(end)
+		loc.Region.EndLine = stop.Line
+		loc.Region.EndColumn = sw.column(stop)
+	}
+	return loc
+}
+
+func (sw *sarifWriter) artifact(filename string) sarifArtifactLocation {
+	if rel, err := filepath.Rel(sw.root, filename); err == nil && !strings.HasPrefix(rel, "..") {
+		return sarifArtifactLocation{URI: filepath.ToSlash(rel), URIBaseID: srcRoot}
+	}
+	return sarifArtifactLocation{URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(filename)}).String()}
+}
+
+// column converts the byte column of posn into the code point column
+// SARIF expects.
+func (sw *sarifWriter) column(posn token.Position) int {
+	lines, ok := sw.lines[posn.Filename]
+	if !ok {
+		content, err := os.ReadFile(posn.Filename)
+		if err == nil {
+			lines = strings.Split(string(content), "\n")
+		}
+		sw.lines[posn.Filename] = lines
+	}
+	if posn.Line < 1 || posn.Line > len(lines) {
+		return posn.Column
+	}
+	line := lines[posn.Line-1]
+	if posn.Column-1 > len(line) {
+		return posn.Column
+	}
+	return utf8.RuneCountInString(line[:posn.Column-1]) + 1
+}
+
+func (sw *sarifWriter) fix(fix analysis.SuggestedFix) sarifFix {
+	out := sarifFix{Description: sarifMessage{fix.Message}}
+	changes := make(map[string]int)
+	for _, edit := range fix.TextEdits {
+		end := edit.End
+		if !end.IsValid() {
+			end = edit.Pos
+		}
+		start, stop := sw.fset.Position(edit.Pos), sw.fset.Position(end)
+		region := sarifRegion{
+			StartLine:   start.Line,
+			StartColumn: sw.column(start),
+			EndLine:     stop.Line,
+			EndColumn:   sw.column(stop),
+		}
+		replacement := sarifReplacement{DeletedRegion: region}
+		if len(edit.NewText) > 0 {
+			replacement.InsertedContent = &sarifMessage{string(edit.NewText)}
+		}
+		i, ok := changes[start.Filename]
+		if !ok {
+			i = len(out.ArtifactChanges)
+			changes[start.Filename] = i
+			out.ArtifactChanges = append(out.ArtifactChanges, sarifArtifactChange{ArtifactLocation: sw.artifact(start.Filename)})
+		}
+		out.ArtifactChanges[i].Replacements = append(out.ArtifactChanges[i].Replacements, replacement)
+	}
+	return out
+}
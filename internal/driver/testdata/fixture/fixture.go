@@ -0,0 +1,19 @@
+package fixture
+
+import "time"
+
+func seen(keys []string) int {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	return len(s)
+}
+
+func stamps(n int) []time.Time {
+	var out []time.Time
+	for range n {
+		out = append(out, time.Now())
+	}
+	return out
+}
@@ -0,0 +1,406 @@
+// Package fixcheck applies suggested fixes in memory and keeps only those
+// that leave every package they touch compiling. It backs smgt -fix and
+// the analyzer tests.
+package fixcheck
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/checker"
+	"golang.org/x/tools/go/packages"
+)
+
+// A Fix is the first suggested fix of a diagnostic.
+type Fix struct {
+	Analyzer   *analysis.Analyzer
+	Diagnostic analysis.Diagnostic
+	// Position is where the diagnostic was reported.
+	Position token.Position
+
+	edits map[string][]edit
+	pkgs  []*packages.Package
+}
+
+func (f *Fix) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Position, f.Analyzer.Name, f.Diagnostic.Message)
+}
+
+type edit struct {
+	start, end int
+	text       string
+}
+
+// A Dropped fix is one that was not applied.
+type Dropped struct {
+	Fix *Fix
+	// Reason is the conflicting fix or the first compile error the fix
+	// introduces.
+	Reason string
+}
+
+// A Result holds the outcome of Verify.
+type Result struct {
+	// Files maps each changed file to its fixed content, formatted if the
+	// file was formatted before.
+	Files   map[string][]byte
+	Applied []*Fix
+	Dropped []Dropped
+}
+
+// Verify collects the fixes of the root actions. A package and its test
+// variant report the same fix twice; identical fixes are merged. A fix
+// whose edits overlap one taken earlier is dropped, as is a fix after
+// which a package it touches, or a loaded package that imports one of
+// those, no longer parses or type checks.
+func Verify(acts []*checker.Action) (*Result, error) {
+	fixes, err := collect(acts)
+	if err != nil {
+		return nil, err
+	}
+	v := newVerifier(acts)
+	res := &Result{}
+
+	var taken []*Fix
+	for _, fix := range fixes {
+		if other := conflict(taken, fix); other != nil {
+			res.Dropped = append(res.Dropped, Dropped{fix, "conflicts with the fix for " + other.String()})
+			continue
+		}
+		taken = append(taken, fix)
+	}
+	// Fixes rarely break the build, so check them all at once first and
+	// only add them one at a time when that fails.
+	if err := v.check(taken); err != nil {
+		if _, ok := err.(compileError); !ok {
+			return nil, err
+		}
+		var applied []*Fix
+		for _, fix := range taken {
+			err := v.check(append(applied, fix))
+			if _, ok := err.(compileError); ok {
+				res.Dropped = append(res.Dropped, Dropped{fix, err.Error()})
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			applied = append(applied, fix)
+		}
+		taken = applied
+	}
+	res.Applied = taken
+
+	files, err := v.apply(taken)
+	if err != nil {
+		return nil, err
+	}
+	for name, content := range files {
+		if files[name], err = v.format(name, content); err != nil {
+			return nil, err
+		}
+	}
+	res.Files = files
+	return res, nil
+}
+
+// collect returns the fixes of the root actions, ordered by the position
+// of their first edit.
+func collect(acts []*checker.Action) ([]*Fix, error) {
+	var fixes []*Fix
+	seen := make(map[string]*Fix)
+	for _, act := range acts {
+		if !act.IsRoot {
+			continue
+		}
+		fset := act.Package.Fset
+		for _, diag := range act.Diagnostics {
+			if len(diag.SuggestedFixes) == 0 {
+				continue
+			}
+			fix := &Fix{
+				Analyzer:   act.Analyzer,
+				Diagnostic: diag,
+				Position:   fset.Position(diag.Pos),
+				edits:      make(map[string][]edit),
+			}
+			for _, te := range diag.SuggestedFixes[0].TextEdits {
+				file := fset.File(te.Pos)
+				if file == nil {
+					return nil, fmt.Errorf("%s: fix edits a position outside the package", fix)
+				}
+				end := te.End
+				if !end.IsValid() {
+					end = te.Pos
+				}
+				e := edit{file.Offset(te.Pos), file.Offset(end), string(te.NewText)}
+				fix.edits[file.Name()] = append(fix.edits[file.Name()], e)
+			}
+			for _, es := range fix.edits {
+				slices.SortFunc(es, func(a, b edit) int { return a.start - b.start })
+			}
+			key := fix.key()
+			if other, ok := seen[key]; ok {
+				other.pkgs = appendPackage(other.pkgs, act.Package)
+				continue
+			}
+			fix.pkgs = []*packages.Package{act.Package}
+			seen[key] = fix
+			fixes = append(fixes, fix)
+		}
+	}
+	slices.SortStableFunc(fixes, func(a, b *Fix) int {
+		if c := strings.Compare(a.Position.Filename, b.Position.Filename); c != 0 {
+			return c
+		}
+		return a.Position.Offset - b.Position.Offset
+	})
+	return fixes, nil
+}
+
+func appendPackage(pkgs []*packages.Package, pkg *packages.Package) []*packages.Package {
+	if slices.Contains(pkgs, pkg) {
+		return pkgs
+	}
+	return append(pkgs, pkg)
+}
+
+// key identifies the edits of a fix.
+func (f *Fix) key() string {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(f.edits)) {
+		for _, e := range f.edits[name] {
+			fmt.Fprintf(&b, "%s:%d:%d:%q\n", name, e.start, e.end, e.text)
+		}
+	}
+	return b.String()
+}
+
+// conflict returns the fix of taken whose edits overlap those of fix.
+// Edits that are identical, such as a shared import, do not conflict.
+func conflict(taken []*Fix, fix *Fix) *Fix {
+	for _, other := range taken {
+		for name, es := range fix.edits {
+			for _, e := range es {
+				for _, o := range other.edits[name] {
+					if e != o && overlap(e, o) {
+						return other
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// overlap reports whether two edits touch the same text. Two insertions
+// at one offset overlap, as their order would be arbitrary.
+func overlap(a, b edit) bool {
+	return a.start < b.end && b.start < a.end || a.start == b.start && (a.start == a.end || b.start == b.end)
+}
+
+// compileError is a fixed package that does not parse or type check.
+type compileError string
+
+func (e compileError) Error() string { return string(e) }
+
+// verifier applies fixes to the files as they were analyzed.
+type verifier struct {
+	sources map[string][]byte
+	// pkgs holds every loaded package, each after its imports.
+	pkgs []*packages.Package
+	// importers maps a package to the loaded packages that import it.
+	importers map[*packages.Package][]*packages.Package
+}
+
+func newVerifier(acts []*checker.Action) *verifier {
+	v := &verifier{
+		sources:   make(map[string][]byte),
+		importers: make(map[*packages.Package][]*packages.Package),
+	}
+	var roots []*packages.Package
+	for _, act := range acts {
+		roots = appendPackage(roots, act.Package)
+	}
+	packages.Visit(roots, nil, func(pkg *packages.Package) {
+		v.pkgs = append(v.pkgs, pkg)
+		for _, imp := range pkg.Imports {
+			v.importers[imp] = append(v.importers[imp], pkg)
+		}
+	})
+	return v
+}
+
+func (v *verifier) source(name string) ([]byte, error) {
+	if content, ok := v.sources[name]; ok {
+		return content, nil
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	v.sources[name] = content
+	return content, nil
+}
+
+// apply returns the content of the files that fixes change.
+func (v *verifier) apply(fixes []*Fix) (map[string][]byte, error) {
+	edits := make(map[string][]edit)
+	for _, fix := range fixes {
+		for name, es := range fix.edits {
+			for _, e := range es {
+				if !slices.Contains(edits[name], e) {
+					edits[name] = append(edits[name], e)
+				}
+			}
+		}
+	}
+	files := make(map[string][]byte)
+	for name, es := range edits {
+		content, err := v.source(name)
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(es, func(a, b edit) int { return a.start - b.start })
+		var out bytes.Buffer
+		last := 0
+		for _, e := range es {
+			out.Write(content[last:e.start])
+			out.WriteString(e.text)
+			last = e.end
+		}
+		out.Write(content[last:])
+		files[name] = out.Bytes()
+	}
+	return files, nil
+}
+
+// format formats the fixed content of a file that gofmt leaves as it
+// was, so that only the lines around the fixes change. Other files keep
+// their layout; formatting them would rewrite lines no fix touched.
+func (v *verifier) format(name string, fixed []byte) ([]byte, error) {
+	content, err := v.source(name)
+	if err != nil {
+		return nil, err
+	}
+	if formatted, err := format.Source(content); err != nil || !bytes.Equal(formatted, content) {
+		return fixed, nil
+	}
+	formatted, err := format.Source(fixed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: fixed file does not parse: %v", name, err)
+	}
+	return formatted, nil
+}
+
+// check type checks every package that fixes touch with the fixes
+// applied, and every loaded package that imports one of them against the
+// fixed packages. It returns a compileError for the first error found.
+func (v *verifier) check(fixes []*Fix) error {
+	files, err := v.apply(fixes)
+	if err != nil {
+		return err
+	}
+	stale := make(map[*packages.Package]bool)
+	var mark func(pkg *packages.Package)
+	mark = func(pkg *packages.Package) {
+		if stale[pkg] {
+			return
+		}
+		stale[pkg] = true
+		for _, importer := range v.importers[pkg] {
+			mark(importer)
+		}
+	}
+	for _, fix := range fixes {
+		for _, pkg := range fix.pkgs {
+			mark(pkg)
+		}
+	}
+
+	fset := token.NewFileSet()
+	checked := make(map[*packages.Package]*types.Package)
+	for _, pkg := range v.pkgs {
+		if !stale[pkg] || len(pkg.Syntax) == 0 {
+			continue
+		}
+		tpkg, err := v.checkPackage(fset, pkg, files, checked)
+		if err != nil {
+			return err
+		}
+		checked[pkg] = tpkg
+	}
+	return nil
+}
+
+// checkPackage type checks pkg with the fixed files. Imports that were
+// checked again resolve to their packages in checked.
+func (v *verifier) checkPackage(fset *token.FileSet, pkg *packages.Package, fixed map[string][]byte, checked map[*packages.Package]*types.Package) (*types.Package, error) {
+	var files []*ast.File
+	for _, f := range pkg.Syntax {
+		name := pkg.Fset.File(f.FileStart).Name()
+		content, ok := fixed[name]
+		if !ok {
+			var err error
+			if content, err = v.source(name); err != nil {
+				return nil, err
+			}
+		}
+		file, err := parser.ParseFile(fset, name, content, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, compileError(err.Error())
+		}
+		files = append(files, file)
+	}
+
+	var first error
+	conf := types.Config{
+		Importer: packageImporter{pkg, checked, importer.ForCompiler(fset, "source", nil)},
+		Sizes:    pkg.TypesSizes,
+		Error: func(err error) {
+			if first == nil {
+				first = err
+			}
+		},
+	}
+	if pkg.Module != nil && pkg.Module.GoVersion != "" {
+		conf.GoVersion = "go" + pkg.Module.GoVersion
+	}
+	tpkg, _ := conf.Check(pkg.PkgPath, fset, files, nil)
+	if first != nil {
+		return nil, compileError(first.Error())
+	}
+	return tpkg, nil
+}
+
+// packageImporter imports the packages that pkg already imports, as
+// checked again after the fixes if they were, and falls back to source
+// for imports that a fix adds.
+type packageImporter struct {
+	pkg      *packages.Package
+	checked  map[*packages.Package]*types.Package
+	fallback types.Importer
+}
+
+func (i packageImporter) Import(path string) (*types.Package, error) {
+	if imp, ok := i.pkg.Imports[path]; ok {
+		if tpkg, ok := i.checked[imp]; ok {
+			return tpkg, nil
+		}
+		if imp.Types != nil {
+			return imp.Types, nil
+		}
+	}
+	return i.fallback.Import(path)
+}
@@ -0,0 +1,158 @@
+package fixcheck_test
+
+import (
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ribice/smgt/internal/fixcheck"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/checker"
+)
+
+// replacer reports every //fix:old=>new comment with a fix that replaces
+// old with new on the comment's line.
+var replacer = &analysis.Analyzer{
+	Name: "replacer",
+	Doc:  "replaces text as its comments say",
+	Run: func(pass *analysis.Pass) (any, error) {
+		for _, file := range pass.Files {
+			for _, group := range file.Comments {
+				for _, c := range group.List {
+					if err := replace(pass, c); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		return nil, nil
+	},
+}
+
+func replace(pass *analysis.Pass, c *ast.Comment) error {
+	tf := pass.Fset.File(c.Pos())
+	content, err := pass.ReadFile(tf.Name())
+	if err != nil {
+		return err
+	}
+	start := tf.LineStart(tf.Line(c.Pos()))
+	line := string(content[tf.Offset(start):tf.Offset(c.Pos())])
+	for _, part := range strings.Split(c.Text, " //") {
+		spec, ok := strings.CutPrefix(strings.TrimPrefix(part, "//"), "fix:")
+		if !ok {
+			continue
+		}
+		old, new, _ := strings.Cut(spec, "=>")
+		pos := start + token.Pos(strings.Index(line, old))
+		pass.Report(analysis.Diagnostic{
+			Pos:     pos,
+			Message: "replace " + old,
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message:   "replace " + old,
+				TextEdits: []analysis.TextEdit{{Pos: pos, End: pos + token.Pos(len(old)), NewText: []byte(new)}},
+			}},
+		})
+	}
+	return nil
+}
+
+// verify runs replacer on the packages in testdata and verifies its
+// fixes.
+func verify(t *testing.T, patterns ...string) *fixcheck.Result {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	var acts []*checker.Action
+	for _, r := range analysistest.Run(t, testdata, replacer, patterns...) {
+		acts = append(acts, r.Action)
+	}
+	res, err := fixcheck.Verify(acts)
+	if err != nil {
+		t.Fatalf("Failed to verify fixes: %s", err)
+	}
+	return res
+}
+
+func TestVerify(t *testing.T) {
+	res := verify(t, "fixes")
+
+	var applied []string
+	for _, fix := range res.Applied {
+		applied = append(applied, fix.Diagnostic.Message)
+	}
+	if want := []string{"replace n * 2", "replace a - b"}; !slices.Equal(applied, want) {
+		t.Errorf("applied %q, want %q", applied, want)
+	}
+
+	want := map[string]string{
+		"replace Println(v)":   "declared and not used: v",
+		"replace err = step()": "no new variables on left side of :=",
+		"replace n++":          "goto done jumps over variable declaration",
+		"replace a":            "conflicts with the fix for",
+	}
+	for _, d := range res.Dropped {
+		reason, ok := want[d.Fix.Diagnostic.Message]
+		if !ok || !strings.Contains(d.Reason, reason) {
+			t.Errorf("%s dropped: %s", d.Fix, d.Reason)
+		}
+		delete(want, d.Fix.Diagnostic.Message)
+	}
+	for message := range want {
+		t.Errorf("fix to %s was not dropped", message)
+	}
+
+	if len(res.Files) != 1 {
+		t.Fatalf("fixed %d files, want 1", len(res.Files))
+	}
+	for _, content := range res.Files {
+		for _, s := range []string{"total := n + n", "return b - a", "fmt.Println(v)", "err = step()", "n++"} {
+			if !strings.Contains(string(content), s) {
+				t.Errorf("fixed file does not contain %q:\n%s", s, content)
+			}
+		}
+	}
+}
+
+// TestFormat checks that a fixed file is formatted only if it was
+// formatted before.
+func TestFormat(t *testing.T) {
+	res := verify(t, "formatted", "unformatted")
+	want := map[string]string{
+		"formatted.go":   "package formatted\n\nfunc double(n int) int {\n\treturn n + n //fix:n * 2=>n  +  n // want `replace n \\* 2`\n}\n",
+		"unformatted.go": "package unformatted\n\nfunc double(n int) int {\n\tm:=n\n\treturn m  +  m //fix:m * 2=>m  +  m // want `replace m \\* 2`\n}\n",
+	}
+	if len(res.Files) != len(want) {
+		t.Fatalf("fixed %d files, want %d", len(res.Files), len(want))
+	}
+	for name, content := range res.Files {
+		if got := string(content); got != want[filepath.Base(name)] {
+			t.Errorf("fixed %s:\n%s", filepath.Base(name), got)
+		}
+	}
+}
+
+// TestVerifyImporters checks that a fix is dropped when a package that
+// imports the fixed one no longer compiles.
+func TestVerifyImporters(t *testing.T) {
+	res := verify(t, "lib", "user")
+
+	var applied []string
+	for _, fix := range res.Applied {
+		applied = append(applied, fix.Diagnostic.Message)
+	}
+	if want := []string{"replace n * 2"}; !slices.Equal(applied, want) {
+		t.Errorf("applied %q, want %q", applied, want)
+	}
+	if len(res.Dropped) != 1 || res.Dropped[0].Fix.Diagnostic.Message != "replace int" || !strings.Contains(res.Dropped[0].Reason, "user.go") {
+		t.Errorf("dropped %+v, want the fix to ID for its use in package user", res.Dropped)
+	}
+}
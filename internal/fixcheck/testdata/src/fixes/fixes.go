@@ -0,0 +1,37 @@
+package fixes
+
+import "fmt"
+
+func step() error { return nil }
+
+func good(n int) int {
+	total := n * 2 //fix:n * 2=>n + n // want `replace n \* 2`
+	return total
+}
+
+func unused(n int) {
+	v := n
+	fmt.Println(v) //fix:Println(v)=>Println(n) // want `replace Println\(v\)`
+}
+
+func redeclared() error {
+	err := step()
+	if err != nil {
+		return err
+	}
+	err = step() //fix:err = step()=>err := step() // want `replace err = step\(\)`
+	return err
+}
+
+func jump(n int) int {
+	if n > 0 {
+		goto done
+	}
+	n++ //fix:n++=>m := n; n = m + 1 // want `replace n\+\+`
+done:
+	return n
+}
+
+func overlap(a, b int) int {
+	return a - b //fix:a - b=>b - a //fix:a=>-a // want `replace a - b` `replace a`
+}
@@ -0,0 +1,5 @@
+package formatted
+
+func double(n int) int {
+	return n * 2 //fix:n * 2=>n  +  n // want `replace n \* 2`
+}
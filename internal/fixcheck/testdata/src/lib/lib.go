@@ -0,0 +1,8 @@
+package lib
+
+// ID compiles on its own as a string, but not in package user.
+type ID int //fix:int=>string // want `replace int`
+
+func Double(n int) int {
+	return n * 2 //fix:n * 2=>n + n // want `replace n \* 2`
+}
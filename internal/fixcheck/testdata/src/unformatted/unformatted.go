@@ -0,0 +1,6 @@
+package unformatted
+
+func double(n int) int {
+	m:=n
+	return m * 2 //fix:m * 2=>m  +  m // want `replace m \* 2`
+}
@@ -0,0 +1,7 @@
+package user
+
+import "lib"
+
+var id lib.ID = 1
+
+var two = lib.Double(1)
@@ -0,0 +1,31 @@
+// Package fixtest runs analyzer tests that also check that the suggested
+// fixes compile.
+package fixtest
+
+import (
+	"testing"
+
+	"github.com/ribice/smgt/internal/fixcheck"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/checker"
+)
+
+// Run runs analysistest.RunWithSuggestedFixes and fails the test for every
+// fix that conflicts with another or breaks the build of its package.
+func Run(t *testing.T, dir string, a *analysis.Analyzer, patterns ...string) []*analysistest.Result {
+	t.Helper()
+	results := analysistest.RunWithSuggestedFixes(t, dir, a, patterns...)
+	var acts []*checker.Action
+	for _, r := range results {
+		acts = append(acts, r.Action)
+	}
+	res, err := fixcheck.Verify(acts)
+	if err != nil {
+		t.Fatalf("Failed to verify fixes: %s", err)
+	}
+	for _, d := range res.Dropped {
+		t.Errorf("%s: fix is not safe: %s", d.Fix, d.Reason)
+	}
+	return results
+}
@@ -2,40 +2,497 @@ package loopnow
 
 import (
 	"flag"
+	"fmt"
 	"go/ast"
+	"go/token"
 	"go/types"
+	"sort"
+	"strings"
 
+	"github.com/ribice/smgt/internal/astutil"
+	"github.com/ribice/smgt/internal/config"
+	"github.com/ribice/smgt/internal/directive"
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/edge"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
 )
 
+// defaultCallbacks lists functions that invoke their function-literal
+// arguments once per element, so those bodies behave like loop bodies.
+var defaultCallbacks = []string{
+	"slices.BinarySearchFunc",
+	"slices.CompactFunc",
+	"slices.ContainsFunc",
+	"slices.DeleteFunc",
+	"slices.EqualFunc",
+	"slices.IndexFunc",
+	"slices.IsSortedFunc",
+	"slices.MaxFunc",
+	"slices.MinFunc",
+	"slices.SortFunc",
+	"slices.SortStableFunc",
+	"sort.Search",
+	"sort.Slice",
+	"sort.SliceStable",
+	"strings.FieldsFunc",
+	"strings.IndexFunc",
+	"strings.Map",
+	"sync.Map.Range",
+}
+
+type settings struct {
+	callbacks  funcList
+	benchmarks benchmarkPolicy
+	tests      bool
+}
+
 func NewAnalyzer() *analysis.Analyzer {
-	return &analysis.Analyzer{
-		Name:  "loopnow",
-		Doc:   "Flags calls to time.Now() inside loops and suggests hoisting them to reduce system calls.",
-		Run:   run,
-		Flags: flag.FlagSet{},
+	a := &analysis.Analyzer{
+		Name:       "loopnow",
+		Doc:        "Flags calls to time.Now() inside loops and suggests hoisting them to reduce system calls.",
+		URL:        "https://github.com/ribice/smgt/blob/main/loopnow/README.md#loopnow-analyzer",
+		Run:        run,
+		Flags:      flag.FlagSet{},
+		ResultType: directive.ResultType,
+		FactTypes:  []analysis.Fact{new(callsNowFact)},
+		Requires:   []*analysis.Analyzer{inspect.Analyzer},
+	}
+	new(settings).register(&a.Flags)
+	return a
+}
+
+// register resets s to the defaults and binds it to fs.
+func (s *settings) register(fs *flag.FlagSet) {
+	*s = settings{
+		callbacks:  newFuncList(defaultCallbacks),
+		benchmarks: benchmarksIgnore,
+	}
+	fs.Var(&s.callbacks, "callbacks", "comma-separated functions whose function-literal arguments run once per element, as pkg.Func or pkg.Type.Method; empty disables")
+	fs.Var(&s.benchmarks, "benchmarks", "how to treat time.Now in testing.B loops: ignore or report")
+	fs.BoolVar(&s.tests, "tests", true, "analyze _test.go files")
+}
+
+// benchmarkPolicy is a flag.Value selecting how benchmark loops are handled.
+type benchmarkPolicy string
+
+const (
+	benchmarksIgnore benchmarkPolicy = "ignore"
+	benchmarksReport benchmarkPolicy = "report"
+)
+
+func (p benchmarkPolicy) String() string {
+	return string(p)
+}
+
+func (p *benchmarkPolicy) Set(value string) error {
+	switch benchmarkPolicy(value) {
+	case benchmarksIgnore, benchmarksReport:
+		*p = benchmarkPolicy(value)
+		return nil
 	}
+	return fmt.Errorf("invalid benchmark policy %q: want ignore or report", value)
+}
+
+// funcList is a flag.Value holding a set of fully qualified function names.
+type funcList map[string]bool
+
+func newFuncList(names []string) funcList {
+	l := make(funcList, len(names))
+	for _, name := range names {
+		l[name] = true
+	}
+	return l
+}
+
+func (l funcList) String() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (l *funcList) Set(value string) error {
+	*l = make(funcList)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		(*l)[name] = true
+	}
+	return nil
+}
+
+// callsNowFact marks a function that calls time.Now on every invocation.
+// Chain lists the calls from the function down to time.Now, starting with
+// the function's own callee.
+type callsNowFact struct {
+	Chain []string
+}
+
+func (*callsNowFact) AFact() {}
+
+func (f *callsNowFact) String() string {
+	return "callsNow(" + strings.Join(f.Chain, " -> ") + ")"
 }
 
 func run(pass *analysis.Pass) (any, error) {
-	for _, file := range pass.Files {
-		parents := buildParents(file)
-		ast.Inspect(file, func(n ast.Node) bool {
-			call, ok := n.(*ast.CallExpr)
-			if !ok {
-				return true
+	s := new(settings)
+	pass, usage, err := config.Apply(pass, s.register)
+	if err != nil || !usage.Ran {
+		return usage, err
+	}
+	_, err = s.run(pass)
+	return usage, err
+}
+
+func (s *settings) run(pass *analysis.Pass) (any, error) {
+	c := &nowCollector{
+		pass:  pass,
+		local: make(map[*types.Func][]string),
+	}
+	c.collect()
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	lf := &loopFinder{
+		pass:      pass,
+		callbacks: s.callbacks,
+		hoisted:   make(map[ast.Node]map[string]bool),
+	}
+
+	// One traversal collects the candidate calls together with the labels
+	// and gotos needed to recognise goto loops; loops are then found by
+	// walking up from each candidate with its cursor.
+	var sites []nowSite
+	labels := make(map[types.Object]inspector.Cursor)
+	var gotos []*ast.BranchStmt
+	filter := []ast.Node{(*ast.CallExpr)(nil), (*ast.LabeledStmt)(nil), (*ast.BranchStmt)(nil)}
+	for fileCur := range inspect.Root().Children() {
+		file := fileCur.Node().(*ast.File)
+		if !s.tests && strings.HasSuffix(pass.Fset.File(file.Pos()).Name(), "_test.go") {
+			continue
+		}
+		for cur := range fileCur.Preorder(filter...) {
+			switch n := cur.Node().(type) {
+			case *ast.LabeledStmt:
+				if obj := pass.TypesInfo.Defs[n.Label]; obj != nil {
+					labels[obj] = cur
+				}
+			case *ast.BranchStmt:
+				if n.Tok == token.GOTO && n.Label != nil {
+					gotos = append(gotos, n)
+				}
+			case *ast.CallExpr:
+				if isTimeNowCall(pass, n) {
+					sites = append(sites, nowSite{cur: cur, call: n})
+					continue
+				}
+				fn := typeutil.StaticCallee(pass.TypesInfo, n)
+				if fn == nil {
+					continue
+				}
+				callee := c.chainOf(fn)
+				if callee == nil {
+					continue
+				}
+				chain := append([]string{c.displayName(fn)}, c.displayChain(callee)...)
+				sites = append(sites, nowSite{cur: cur, call: n, chain: chain})
 			}
-			if !isTimeNowCall(pass, call) {
+		}
+	}
+	for _, branch := range gotos {
+		label, ok := labels[pass.TypesInfo.Uses[branch.Label]]
+		if !ok || label.Node().Pos() > branch.Pos() {
+			continue
+		}
+		lf.gotoLoops = append(lf.gotoLoops, gotoLoop{label: label, end: branch.End()})
+	}
+
+	var groups []*loopGroup
+	byLoop := make(map[ast.Node]*loopGroup)
+	for _, site := range sites {
+		loop, ok := lf.enclosingLoop(site.cur)
+		if !ok {
+			continue
+		}
+		group := byLoop[loop.Node()]
+		if group == nil {
+			group = &loopGroup{loop: loop, benchmark: lf.isBenchmarkLoop(loop.Node())}
+			byLoop[loop.Node()] = group
+			groups = append(groups, group)
+		}
+		group.sites = append(group.sites, site)
+	}
+	for _, group := range groups {
+		if group.benchmark && s.benchmarks != benchmarksReport {
+			continue
+		}
+		pass.Report(lf.diagnostic(group))
+	}
+	return nil, nil
+}
+
+// loopGroup collects every call reaching time.Now within one loop, so the
+// loop is reported once.
+type loopGroup struct {
+	loop      inspector.Cursor
+	benchmark bool
+	sites     []nowSite
+}
+
+// nowSite is a call to time.Now, or to a helper reaching it through chain.
+type nowSite struct {
+	cur   inspector.Cursor
+	call  *ast.CallExpr
+	chain []string
+}
+
+func (lf *loopFinder) diagnostic(group *loopGroup) analysis.Diagnostic {
+	diag := analysis.Diagnostic{
+		Pos:     loopPos(group.loop.Node()),
+		Message: groupMessage(group),
+	}
+	for _, site := range group.sites {
+		diag.Related = append(diag.Related, analysis.RelatedInformation{
+			Pos:     site.call.Pos(),
+			End:     site.call.End(),
+			Message: siteMessage(site.chain),
+		})
+	}
+	switch {
+	case group.benchmark:
+		diag.Category = "benchmark"
+		return diag
+	case lf.polls(group):
+		diag.Category = "polling"
+		return diag
+	}
+	diag.Category = "hoist"
+	if fix, ok := lf.hoistFix(group); ok {
+		diag.SuggestedFixes = []analysis.SuggestedFix{fix}
+	}
+	return diag
+}
+
+// polls reports whether the loop of group reads the clock to decide when
+// to stop, or blocks between reads, so time passes between iterations and
+// the calls cannot simply be hoisted.
+func (lf *loopFinder) polls(group *loopGroup) bool {
+	switch l := group.loop.Node().(type) {
+	case *ast.ForStmt:
+		for _, site := range group.sites {
+			if astutil.Within(site.cur, l.Cond) || astutil.Within(site.cur, l.Post) {
 				return true
 			}
-			if !inLoop(call, parents) {
+		}
+		return lf.blocks(l)
+	case *ast.RangeStmt:
+		return lf.blocks(l)
+	}
+	return false
+}
+
+func loopPos(loop ast.Node) token.Pos {
+	switch l := loop.(type) {
+	case *ast.ForStmt:
+		return l.For
+	case *ast.RangeStmt:
+		return l.For
+	case *ast.CallExpr:
+		return l.Fun.Pos()
+	}
+	return loop.Pos()
+}
+
+func groupMessage(group *loopGroup) string {
+	if len(group.sites) == 1 {
+		if group.benchmark {
+			return benchmarkMessage(group.sites[0].chain)
+		}
+		return loopMessage(group.sites[0].chain)
+	}
+	if group.benchmark {
+		return fmt.Sprintf("time.Now is reached from %d call sites on every benchmark iteration and adds to the measured time; move it out of the benchmark loop unless it is under test", len(group.sites))
+	}
+	return fmt.Sprintf("time.Now is reached from %d call sites on every iteration; compute the value once outside the loop", len(group.sites))
+}
+
+func siteMessage(chain []string) string {
+	if chain == nil {
+		return "time.Now called here"
+	}
+	return "time.Now reached via " + strings.Join(chain, " -> ")
+}
+
+func loopMessage(chain []string) string {
+	if chain == nil {
+		return "time.Now should not be called inside loops; compute the value outside the loop"
+	}
+	return fmt.Sprintf("%s calls time.Now on every iteration (%s); compute the value outside the loop", chain[0], strings.Join(chain, " -> "))
+}
+
+func benchmarkMessage(chain []string) string {
+	if chain == nil {
+		return "time.Now is called on every benchmark iteration and adds to the measured time; move it out of the benchmark loop unless it is under test"
+	}
+	return fmt.Sprintf("%s calls time.Now on every benchmark iteration (%s) and adds to the measured time; move it out of the benchmark loop unless it is under test", chain[0], strings.Join(chain, " -> "))
+}
+
+// hoistFix declares one value before the loop and replaces every time.Now
+// call with it. It is only offered when the calls are interchangeable: all
+// of them are direct time.Now calls made by the loop itself rather than by
+// a goroutine or function literal in it, none drives the loop condition,
+// and nothing in the loop sleeps or blocks between them.
+func (lf *loopFinder) hoistFix(group *loopGroup) (analysis.SuggestedFix, bool) {
+	var stmt ast.Stmt
+	switch l := group.loop.Node().(type) {
+	case *ast.ForStmt:
+		stmt = l
+	case *ast.RangeStmt:
+		stmt = l
+	default:
+		return analysis.SuggestedFix{}, false
+	}
+	for _, site := range group.sites {
+		if site.chain != nil || inFuncLit(site.cur, group.loop) {
+			return analysis.SuggestedFix{}, false
+		}
+	}
+	if labeled, ok := group.loop.Parent().Node().(*ast.LabeledStmt); ok {
+		stmt = labeled
+	}
+
+	var fn ast.Node
+	if c, ok := astutil.EnclosingFunc(group.loop); ok {
+		fn = c.Node()
+	}
+	if lf.hoisted[fn] == nil {
+		lf.hoisted[fn] = make(map[string]bool)
+	}
+	name := lf.freeName(stmt, blockScope(lf.pass.TypesInfo, group.loop), lf.hoisted[fn], "now")
+	lf.hoisted[fn][name] = true
+	first := group.sites[0].call
+	decl := name + " := " + types.ExprString(first) + "\n" + lf.indent(stmt.Pos())
+	edits := []analysis.TextEdit{{Pos: stmt.Pos(), End: stmt.Pos(), NewText: []byte(decl)}}
+	for _, site := range group.sites {
+		edits = append(edits, analysis.TextEdit{Pos: site.call.Pos(), End: site.call.End(), NewText: []byte(name)})
+	}
+	return analysis.SuggestedFix{
+		Message:   "Hoist time.Now out of the loop",
+		TextEdits: edits,
+	}, true
+}
+
+// inFuncLit reports whether the call at cur is made by a function literal
+// inside loop, such as the body of a goroutine, which need not run when
+// the loop body does.
+func inFuncLit(cur, loop inspector.Cursor) bool {
+	fn, ok := astutil.EnclosingFunc(cur)
+	if !ok {
+		return false
+	}
+	_, lit := fn.Node().(*ast.FuncLit)
+	return lit && loop.Contains(fn)
+}
+
+// blocks reports whether loop sleeps, communicates over channels or waits
+// on a lock, any of which makes the time.Now calls around it differ.
+func (lf *loopFinder) blocks(loop ast.Stmt) bool {
+	found := false
+	ast.Inspect(loop, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		switch e := n.(type) {
+		case *ast.SendStmt, *ast.SelectStmt:
+			found = true
+		case *ast.UnaryExpr:
+			found = e.Op == token.ARROW
+		case *ast.RangeStmt:
+			_, found = lf.pass.TypesInfo.TypeOf(e.X).Underlying().(*types.Chan)
+		case *ast.CallExpr:
+			fn := typeutil.StaticCallee(lf.pass.TypesInfo, e)
+			if fn == nil {
 				return true
 			}
-			pass.Reportf(call.Fun.Pos(), "time.Now should not be called inside loops; compute the value outside the loop")
-			return true
-		})
+			switch pathName(fn) {
+			case "time.Sleep", "sync.Mutex.Lock", "sync.RWMutex.Lock", "sync.RWMutex.RLock", "sync.WaitGroup.Wait", "sync.Cond.Wait":
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// freeName returns base, or base with a numeric suffix, such that it is
+// neither visible at stmt, declared inside it, declared later in block,
+// the scope holding stmt, nor in taken.
+func (lf *loopFinder) freeName(stmt ast.Stmt, block *types.Scope, taken map[string]bool, base string) string {
+	declared := make(map[string]bool)
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && lf.pass.TypesInfo.Defs[ident] != nil {
+			declared[ident.Name] = true
+		}
+		return true
+	})
+	scope := lf.pass.Pkg.Scope().Innermost(stmt.Pos())
+	name := base
+	for i := 1; ; i++ {
+		if !declared[name] && !taken[name] && !visible(scope, name, stmt.Pos()) && (block == nil || block.Lookup(name) == nil) {
+			return name
+		}
+		name = fmt.Sprintf("%s%d", base, i)
 	}
-	return nil, nil
+}
+
+// blockScope returns the innermost scope around the statement at cur.
+// Function bodies have no scope of their own; their scope is that of the
+// signature.
+func blockScope(info *types.Info, cur inspector.Cursor) *types.Scope {
+	for c := range cur.Parent().Enclosing() {
+		switch n := c.Node().(type) {
+		case *ast.FuncDecl:
+			return info.Scopes[n.Type]
+		case *ast.FuncLit:
+			return info.Scopes[n.Type]
+		default:
+			if scope := info.Scopes[n]; scope != nil {
+				return scope
+			}
+		}
+	}
+	return nil
+}
+
+func visible(scope *types.Scope, name string, pos token.Pos) bool {
+	if scope == nil {
+		return false
+	}
+	_, obj := scope.LookupParent(name, pos)
+	return obj != nil
+}
+
+// indent returns the leading whitespace of the line containing pos.
+func (lf *loopFinder) indent(pos token.Pos) string {
+	tf := lf.pass.Fset.File(pos)
+	if tf == nil {
+		return ""
+	}
+	content, err := lf.pass.ReadFile(tf.Name())
+	if err != nil {
+		return ""
+	}
+	offset := tf.Offset(tf.LineStart(tf.Line(pos)))
+	end := offset
+	for end < len(content) && (content[end] == ' ' || content[end] == '\t') {
+		end++
+	}
+	return string(content[offset:end])
 }
 
 func isTimeNowCall(pass *analysis.Pass, call *ast.CallExpr) bool {
@@ -61,29 +518,335 @@ func isTimeNowCall(pass *analysis.Pass, call *ast.CallExpr) bool {
 	return true
 }
 
-func inLoop(node ast.Node, parents map[ast.Node]ast.Node) bool {
-	for parent := parents[node]; parent != nil; parent = parents[parent] {
-		switch parent.(type) {
-		case *ast.ForStmt, *ast.RangeStmt:
-			return true
+// nowCollector computes which functions of the package unconditionally reach
+// time.Now, either directly or through helpers that carry a callsNowFact.
+type nowCollector struct {
+	pass  *analysis.Pass
+	local map[*types.Func][]string
+}
+
+func (c *nowCollector) collect() {
+	var decls []*ast.FuncDecl
+	for _, file := range c.pass.Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Body == nil {
+				continue
+			}
+			decls = append(decls, fn)
 		}
 	}
-	return false
+
+	// Helpers may call each other in any order, so iterate until no new
+	// function is found to reach time.Now.
+	for changed := true; changed; {
+		changed = false
+		for _, decl := range decls {
+			fn, ok := c.pass.TypesInfo.Defs[decl.Name].(*types.Func)
+			if !ok {
+				continue
+			}
+			if _, done := c.local[fn]; done {
+				continue
+			}
+			chain, _ := c.stmtsChain(decl.Body.List)
+			if chain == nil {
+				continue
+			}
+			c.local[fn] = chain
+			changed = true
+		}
+	}
+
+	for fn, chain := range c.local {
+		c.pass.ExportObjectFact(fn, &callsNowFact{Chain: chain})
+	}
+}
+
+func (c *nowCollector) chainOf(fn *types.Func) []string {
+	fn = fn.Origin()
+	if fn.Pkg() == c.pass.Pkg {
+		return c.local[fn]
+	}
+	var fact callsNowFact
+	if !c.pass.ImportObjectFact(fn, &fact) {
+		return nil
+	}
+	return fact.Chain
+}
+
+// stmtsChain returns the chain to time.Now for the first call reached by every
+// execution of stmts. The boolean reports whether control may leave the list
+// early, after which later statements are no longer unconditional.
+func (c *nowCollector) stmtsChain(stmts []ast.Stmt) ([]string, bool) {
+	for _, stmt := range stmts {
+		chain, exits := c.stmtChain(stmt)
+		if chain != nil {
+			return chain, false
+		}
+		if exits {
+			return nil, true
+		}
+	}
+	return nil, false
 }
 
-func buildParents(root ast.Node) map[ast.Node]ast.Node {
-	parents := make(map[ast.Node]ast.Node)
-	var stack []ast.Node
-	ast.Inspect(root, func(n ast.Node) bool {
-		if n != nil {
-			if len(stack) > 0 {
-				parents[n] = stack[len(stack)-1]
+func (c *nowCollector) stmtChain(stmt ast.Stmt) ([]string, bool) {
+	switch s := stmt.(type) {
+	case *ast.ExprStmt, *ast.AssignStmt, *ast.DeclStmt, *ast.IncDecStmt, *ast.SendStmt:
+		return c.exprChain(s), astutil.MayExit(s)
+	case *ast.DeferStmt:
+		return c.exprChain(s.Call), false
+	case *ast.GoStmt:
+		for _, arg := range s.Call.Args {
+			if chain := c.exprChain(arg); chain != nil {
+				return chain, false
 			}
-			stack = append(stack, n)
+		}
+		return nil, false
+	case *ast.ReturnStmt:
+		return c.exprChain(s), true
+	case *ast.BlockStmt:
+		return c.stmtsChain(s.List)
+	case *ast.LabeledStmt:
+		return c.stmtChain(s.Stmt)
+	case *ast.IfStmt:
+		if chain := c.optChain(s.Init, s.Cond); chain != nil {
+			return chain, false
+		}
+		return nil, astutil.MayExit(s)
+	case *ast.ForStmt:
+		if chain := c.optChain(s.Init, s.Cond); chain != nil {
+			return chain, false
+		}
+		return nil, astutil.MayExit(s)
+	case *ast.RangeStmt:
+		if chain := c.exprChain(s.X); chain != nil {
+			return chain, false
+		}
+		return nil, astutil.MayExit(s)
+	case *ast.SwitchStmt:
+		if chain := c.optChain(s.Init, s.Tag); chain != nil {
+			return chain, false
+		}
+		return nil, astutil.MayExit(s)
+	case *ast.TypeSwitchStmt:
+		if chain := c.optChain(s.Init, s.Assign); chain != nil {
+			return chain, false
+		}
+		return nil, astutil.MayExit(s)
+	case *ast.BranchStmt:
+		return nil, s.Tok == token.GOTO
+	}
+	return nil, astutil.MayExit(stmt)
+}
+
+func (c *nowCollector) optChain(nodes ...ast.Node) []string {
+	for _, n := range nodes {
+		if chain := c.exprChain(n); chain != nil {
+			return chain
+		}
+	}
+	return nil
+}
+
+// exprChain looks for calls evaluated whenever node is evaluated. Function
+// literals and the right operand of && and || are skipped because they may
+// never run.
+func (c *nowCollector) exprChain(node ast.Node) []string {
+	if node == nil {
+		return nil
+	}
+	var found []string
+	ast.Inspect(node, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		switch e := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BinaryExpr:
+			if e.Op == token.LAND || e.Op == token.LOR {
+				found = c.exprChain(e.X)
+				return false
+			}
+		case *ast.CallExpr:
+			if isTimeNowCall(c.pass, e) {
+				found = []string{"time.Now"}
+				return false
+			}
+			fn := typeutil.StaticCallee(c.pass.TypesInfo, e)
+			if fn == nil {
+				return true
+			}
+			if chain := c.chainOf(fn); chain != nil {
+				found = append([]string{qualifiedName(fn)}, chain...)
+				return false
+			}
+		}
+		return true
+	})
+	return found
+}
+
+func qualifiedName(fn *types.Func) string {
+	return packagePrefix(fn.Pkg()) + recvPrefix(fn) + fn.Name()
+}
+
+// pathName identifies fn by its package path, as accepted by -callbacks.
+func pathName(fn *types.Func) string {
+	if fn.Pkg() == nil {
+		return fn.Name()
+	}
+	return fn.Pkg().Path() + "." + recvPrefix(fn) + fn.Name()
+}
+
+func recvPrefix(fn *types.Func) string {
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return ""
+	}
+	recv := sig.Recv().Type()
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok {
+		return ""
+	}
+	return named.Obj().Name() + "."
+}
+
+func packagePrefix(pkg *types.Package) string {
+	if pkg == nil {
+		return ""
+	}
+	return pkg.Name() + "."
+}
+
+func (c *nowCollector) displayName(fn *types.Func) string {
+	return c.trimLocal(qualifiedName(fn))
+}
+
+func (c *nowCollector) displayChain(chain []string) []string {
+	out := make([]string, len(chain))
+	for i, name := range chain {
+		out[i] = c.trimLocal(name)
+	}
+	return out
+}
+
+func (c *nowCollector) trimLocal(name string) string {
+	return strings.TrimPrefix(name, c.pass.Pkg.Name()+".")
+}
+
+// loopFinder answers whether a node executes once per iteration of some
+// loop in the package.
+type loopFinder struct {
+	pass      *analysis.Pass
+	gotoLoops []gotoLoop
+	callbacks funcList
+	// hoisted holds the names the fixes declare in each function, so
+	// that fixes for sibling loops do not pick the same one.
+	hoisted map[ast.Node]map[string]bool
+}
+
+// gotoLoop spans from a label to the last goto that jumps back to it.
+type gotoLoop struct {
+	label inspector.Cursor
+	end   token.Pos
+}
+
+// enclosingLoop returns the innermost loop that runs cur once per
+// iteration: a *ast.ForStmt, *ast.RangeStmt, the *ast.LabeledStmt targeted
+// by a backward goto, or the *ast.CallExpr of a per-element callback.
+func (lf *loopFinder) enclosingLoop(cur inspector.Cursor) (inspector.Cursor, bool) {
+	var loop inspector.Cursor
+	found := false
+	for child := cur; !found && child.Parent().Node() != nil; child = child.Parent() {
+		parent := child.Parent()
+		kind, _ := child.ParentEdge()
+		switch kind {
+		case edge.ForStmt_Cond, edge.ForStmt_Post, edge.ForStmt_Body:
+			// Init runs once; the condition, post statement and body repeat.
+			loop, found = parent, true
+		case edge.RangeStmt_Body:
+			// The ranged expression is evaluated once. For range-over-func
+			// the body runs inside the yield callback, once per element.
+			loop, found = parent, true
+		case edge.CallExpr_Args:
+			if _, ok := child.Node().(*ast.FuncLit); ok && lf.isCallback(parent.Node().(*ast.CallExpr)) {
+				loop, found = parent, true
+			}
+		}
+	}
+	node := cur.Node()
+	for _, g := range lf.gotoLoops {
+		label := g.label.Node()
+		if label.Pos() > node.Pos() || node.End() > g.end {
+			continue
+		}
+		if !found || label.Pos() > loop.Node().Pos() {
+			loop, found = g.label, true
+		}
+	}
+	return loop, found
+}
+
+// isBenchmarkLoop reports whether loop is driven by a *testing.B, as in
+// for b.Loop(), for i := 0; i < b.N; i++ or for range b.N.
+func (lf *loopFinder) isBenchmarkLoop(loop ast.Node) bool {
+	switch l := loop.(type) {
+	case *ast.ForStmt:
+		return l.Cond != nil && lf.usesBenchmark(l.Cond)
+	case *ast.RangeStmt:
+		return lf.usesBenchmark(l.X)
+	}
+	return false
+}
+
+func (lf *loopFinder) usesBenchmark(expr ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if found {
+			return false
+		}
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
 			return true
 		}
-		stack = stack[:len(stack)-1]
-		return false
+		if sel.Sel.Name != "N" && sel.Sel.Name != "Loop" {
+			return true
+		}
+		selection := lf.pass.TypesInfo.Selections[sel]
+		if selection == nil {
+			return true
+		}
+		found = isTestingB(selection.Recv())
+		return !found
 	})
-	return parents
+	return found
+}
+
+func isTestingB(typ types.Type) bool {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == "testing" && obj.Name() == "B"
+}
+
+func (lf *loopFinder) isCallback(call *ast.CallExpr) bool {
+	if len(lf.callbacks) == 0 {
+		return false
+	}
+	fn := typeutil.StaticCallee(lf.pass.TypesInfo, call)
+	if fn == nil {
+		return false
+	}
+	return lf.callbacks[pathName(fn)]
 }
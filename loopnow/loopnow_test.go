@@ -1,11 +1,24 @@
 package loopnow
 
 import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
+	"github.com/ribice/smgt/internal/fixtest"
+
+	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
 )
 
 func TestAll(t *testing.T) {
@@ -17,3 +30,149 @@ func TestAll(t *testing.T) {
 	testdata := filepath.Join(wd, "testdata")
 	analysistest.Run(t, testdata, NewAnalyzer(), "loopnow")
 }
+
+func TestCallbacksFlag(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	analyzer := NewAnalyzer()
+	if err := analyzer.Flags.Set("callbacks", "sync.Map.Range"); err != nil {
+		t.Fatalf("Failed to set callbacks: %s", err)
+	}
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, analyzer, "callbacks")
+}
+
+func TestBenchmarksReport(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	analyzer := NewAnalyzer()
+	if err := analyzer.Flags.Set("benchmarks", "report"); err != nil {
+		t.Fatalf("Failed to set benchmarks: %s", err)
+	}
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, analyzer, "benchreport")
+}
+
+func TestSkipTests(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	analyzer := NewAnalyzer()
+	if err := analyzer.Flags.Set("tests", "false"); err != nil {
+		t.Fatalf("Failed to set tests: %s", err)
+	}
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, analyzer, "notests")
+}
+
+func TestHoistFix(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	fixtest.Run(t, testdata, NewAnalyzer(), "hoist")
+}
+
+// syntheticPackage type-checks a generated package with files files of funcs
+// functions each, mixing plain loops, nested loops, helpers and time.Now.
+func syntheticPackage(tb testing.TB, files, funcs int) (*token.FileSet, []*ast.File, *types.Package, *types.Info, map[string][]byte) {
+	tb.Helper()
+	fset := token.NewFileSet()
+	sources := make(map[string][]byte)
+	var parsed []*ast.File
+	for f := 0; f < files; f++ {
+		var b strings.Builder
+		b.WriteString("package synthetic\n\nimport \"time\"\n\n")
+		for i := 0; i < funcs; i++ {
+			fmt.Fprintf(&b, "func stamp%d_%d(ts []time.Time) { ts[0] = time.Now() }\n\n", f, i)
+			fmt.Fprintf(&b, "func work%d_%d(xs []int, ts []time.Time) int {\n", f, i)
+			b.WriteString("\ttotal := 0\n\tfor i, x := range xs {\n\t\tif x%2 == 0 {\n\t\t\ttotal += x * i\n\t\t}\n")
+			b.WriteString("\t\tfor j := 0; j < x; j++ {\n\t\t\ttotal -= j\n\t\t}\n\t}\n")
+			if i%4 == 0 {
+				fmt.Fprintf(&b, "\tfor range xs {\n\t\tts[0] = time.Now()\n\t\tstamp%d_%d(ts)\n\t}\n", f, i)
+			}
+			b.WriteString("\treturn total\n}\n\n")
+		}
+		name := fmt.Sprintf("synthetic%d.go", f)
+		sources[name] = []byte(b.String())
+		file, err := parser.ParseFile(fset, name, sources[name], parser.ParseComments)
+		if err != nil {
+			tb.Fatalf("Failed to parse synthetic package: %s", err)
+		}
+		parsed = append(parsed, file)
+	}
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := conf.Check("synthetic", fset, parsed, info)
+	if err != nil {
+		tb.Fatalf("Failed to type-check synthetic package: %s", err)
+	}
+	return fset, parsed, pkg, info, sources
+}
+
+func BenchmarkRun(b *testing.B) {
+	fset, files, pkg, info, sources := syntheticPackage(b, 50, 100)
+	analyzer := NewAnalyzer()
+	// The inspector is built once per package by inspect.Analyzer and shared
+	// by every analyzer that requires it, so it is not part of the loop.
+	in := inspector.New(files)
+	b.ReportAllocs()
+	for b.Loop() {
+		pass := &analysis.Pass{
+			Analyzer:  analyzer,
+			Fset:      fset,
+			Files:     files,
+			Pkg:       pkg,
+			TypesInfo: info,
+			ResultOf: map[*analysis.Analyzer]any{
+				inspect.Analyzer: in,
+			},
+			Report:           func(analysis.Diagnostic) {},
+			ImportObjectFact: func(types.Object, analysis.Fact) bool { return false },
+			ExportObjectFact: func(types.Object, analysis.Fact) {},
+			ReadFile: func(name string) ([]byte, error) {
+				return sources[name], nil
+			},
+		}
+		if _, err := analyzer.Run(pass); err != nil {
+			b.Fatalf("Run failed: %s", err)
+		}
+	}
+}
+
+func TestCategories(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	results := analysistest.Run(t, testdata, NewAnalyzer(), "loopnowcategory")
+	var got []string
+	for _, result := range results {
+		for _, diag := range result.Diagnostics {
+			got = append(got, diag.Category)
+		}
+	}
+	if want := []string{"hoist", "polling", "polling"}; !slices.Equal(got, want) {
+		t.Errorf("categories = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,22 @@
+package benchreport
+
+import (
+	"testing"
+	"time"
+)
+
+func stamp() time.Time { // want stamp:`callsNow\(time.Now\)`
+	return time.Now()
+}
+
+func BenchmarkLoop(b *testing.B) {
+	for b.Loop() { // want "time.Now is called on every benchmark iteration and adds to the measured time; move it out of the benchmark loop unless it is under test"
+		_ = time.Now()
+	}
+}
+
+func BenchmarkN(b *testing.B) {
+	for i := 0; i < b.N; i++ { // want `stamp calls time.Now on every benchmark iteration \(stamp -> time.Now\) and adds to the measured time`
+		_ = stamp()
+	}
+}
@@ -0,0 +1,20 @@
+package callbacks
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+func sortCallback(xs []time.Time) {
+	slices.SortFunc(xs, func(a, b time.Time) int {
+		return a.Compare(time.Now())
+	})
+}
+
+func syncMapRange(m *sync.Map) {
+	m.Range(func(k, v any) bool { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		_ = time.Now()
+		return true
+	})
+}
@@ -0,0 +1,38 @@
+package clockhelper
+
+import "time"
+
+type Recorder struct {
+	last time.Time
+}
+
+func Stamp() int64 {
+	return time.Now().UnixNano()
+}
+
+func Wrapped() int64 {
+	return Stamp()
+}
+
+func (r *Recorder) Record() {
+	r.last = time.Now()
+}
+
+func Maybe(enabled bool) {
+	if enabled {
+		_ = time.Now()
+	}
+}
+
+func AfterGuard(enabled bool) time.Time {
+	if !enabled {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func Deferred() func() time.Time {
+	return func() time.Time {
+		return time.Now()
+	}
+}
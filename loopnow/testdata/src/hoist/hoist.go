@@ -0,0 +1,82 @@
+package hoist
+
+import (
+	"time"
+)
+
+type entry struct {
+	created, updated time.Time
+}
+
+func shared(entries []*entry) {
+	for _, e := range entries { // want "time.Now is reached from 2 call sites on every iteration; compute the value once outside the loop"
+		e.created = time.Now()
+		e.updated = time.Now()
+	}
+}
+
+func nameTaken(entries []*entry, now time.Time) {
+	for i := 0; i < len(entries); i++ { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		entries[i].created = now
+		entries[i].updated = time.Now()
+	}
+}
+
+func declaredLater(entries []*entry) int {
+	for _, e := range entries { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		e.created = time.Now()
+	}
+	now := len(entries)
+	return now
+}
+
+func labeled(groups [][]*entry) {
+outer:
+	for _, group := range groups { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		for _, e := range group {
+			if e == nil {
+				continue outer
+			}
+		}
+		if time.Now().IsZero() {
+			break
+		}
+	}
+}
+
+func sleeps(entries []*entry) {
+	for _, e := range entries { // want "time.Now is reached from 2 call sites on every iteration; compute the value once outside the loop"
+		e.created = time.Now()
+		time.Sleep(time.Millisecond)
+		e.updated = time.Now()
+	}
+}
+
+func receives(entries []*entry, ready chan struct{}) {
+	for _, e := range entries { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		<-ready
+		e.created = time.Now()
+	}
+}
+
+func polling(deadline time.Time) { // want polling:`callsNow\(time.Now\)`
+	for time.Now().Before(deadline) { // want "time.Now should not be called inside loops; compute the value outside the loop"
+	}
+}
+
+func goroutines(entries []*entry) {
+	for _, e := range entries { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		go func() {
+			e.created = time.Now()
+		}()
+	}
+}
+
+func siblings(entries []*entry) {
+	for _, e := range entries { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		e.created = time.Now()
+	}
+	for _, e := range entries { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		e.updated = time.Now()
+	}
+}
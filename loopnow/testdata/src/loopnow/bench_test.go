@@ -0,0 +1,39 @@
+package loopnow
+
+import (
+	"testing"
+	"time"
+)
+
+func BenchmarkLoop(b *testing.B) {
+	for b.Loop() {
+		_ = time.Now()
+	}
+}
+
+func BenchmarkN(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_ = time.Now()
+		stamp(&event{})
+	}
+}
+
+func BenchmarkRangeN(b *testing.B) {
+	for range b.N {
+		_ = time.Now()
+	}
+}
+
+func BenchmarkNestedLoop(b *testing.B) {
+	for b.Loop() {
+		for i := 0; i < 3; i++ { // want "time.Now should not be called inside loops; compute the value outside the loop"
+			_ = time.Now()
+		}
+	}
+}
+
+func TestLoop(t *testing.T) {
+	for i := 0; i < 3; i++ { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		_ = time.Now()
+	}
+}
@@ -5,20 +5,20 @@ import (
 )
 
 func positiveBody() {
-	for i := 0; i < 3; i++ {
-		now := t.Now() // want "time.Now should not be called inside loops; compute the value outside the loop"
+	for i := 0; i < 3; i++ { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		now := t.Now()
 		_ = now
 	}
 }
 
 func positiveRange(xs []int) {
-	for _, x := range xs {
+	for _, x := range xs { // want "time.Now should not be called inside loops; compute the value outside the loop"
 		_ = x
-		_ = t.Now().Unix() // want "time.Now should not be called inside loops; compute the value outside the loop"
+		_ = t.Now().Unix()
 	}
 }
 
-func positiveCondition(limit t.Duration) {
+func positiveCondition(limit t.Duration) { // want positiveCondition:`callsNow\(time.Now\)`
 	start := t.Now()
 	for t.Now().Before(start.Add(limit)) { // want "time.Now should not be called inside loops; compute the value outside the loop"
 		return
@@ -26,14 +26,14 @@ func positiveCondition(limit t.Duration) {
 }
 
 func positiveNested(limit int) {
-	for i := 0; i < limit; i++ {
+	for i := 0; i < limit; i++ { // want "time.Now should not be called inside loops; compute the value outside the loop"
 		func() {
-			_ = t.Now() // want "time.Now should not be called inside loops; compute the value outside the loop"
+			_ = t.Now()
 		}()
 	}
 }
 
-func negativeOutsideLoop() {
+func negativeOutsideLoop() { // want negativeOutsideLoop:`callsNow\(time.Now\)`
 	start := t.Now()
 	deadline := start.Add(10 * t.Second)
 	for start.Before(deadline) {
@@ -47,7 +47,7 @@ func negativeDifferentPackage(now func() t.Time) {
 	}
 }
 
-func negativeOtherCall(xs []int) {
+func negativeOtherCall(xs []int) { // want negativeOtherCall:`callsNow\(time.Now\)`
 	start := t.Now()
 	for range xs {
 		_ = t.Since(start)
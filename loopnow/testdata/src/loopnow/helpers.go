@@ -0,0 +1,60 @@
+package loopnow
+
+import (
+	"clockhelper"
+	"fmt"
+	"time"
+)
+
+type event struct {
+	name string
+	at   time.Time
+}
+
+func stamp(e *event) { // want stamp:`callsNow\(time.Now\)`
+	e.at = time.Now()
+}
+
+func logEvent(e *event) { // want logEvent:`callsNow\(loopnow.stamp -> time.Now\)`
+	stamp(e)
+	fmt.Println(e.name)
+}
+
+func logIfNamed(e *event) {
+	if e.name != "" && time.Now().IsZero() {
+		return
+	}
+}
+
+func helperInLoop(events []*event) {
+	for _, e := range events { // want `logEvent calls time.Now on every iteration \(logEvent -> stamp -> time.Now\); compute the value outside the loop`
+		logEvent(e)
+	}
+}
+
+func directHelperInLoop(events []*event) {
+	for _, e := range events { // want `stamp calls time.Now on every iteration \(stamp -> time.Now\); compute the value outside the loop`
+		stamp(e)
+	}
+}
+
+func importedHelperInLoop(n int) {
+	var r clockhelper.Recorder
+	for i := 0; i < n; i++ { // want `time.Now is reached from 2 call sites on every iteration; compute the value once outside the loop`
+		_ = clockhelper.Wrapped()
+		r.Record()
+	}
+}
+
+func conditionalHelpersInLoop(events []*event) {
+	for _, e := range events {
+		logIfNamed(e)
+		clockhelper.Maybe(e.name != "")
+		_ = clockhelper.AfterGuard(true)
+		_ = clockhelper.Deferred()
+	}
+}
+
+func helperOutsideLoop(e *event) { // want helperOutsideLoop:`callsNow\(loopnow.logEvent -> loopnow.stamp -> time.Now\)`
+	logEvent(e)
+}
@@ -0,0 +1,78 @@
+package loopnow
+
+import (
+	"iter"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+func seq(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func rangeOverFunc(n int) {
+	for i := range seq(n) { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		_ = i
+		_ = time.Now()
+	}
+}
+
+func rangeExpressionOnce(byTime map[int64][]int) { // want rangeExpressionOnce:`callsNow\(time.Now\)`
+	for _, x := range byTime[time.Now().Unix()] {
+		_ = x
+	}
+}
+
+func forInitOnce(n int) { // want forInitOnce:`callsNow\(time.Now\)`
+	for start, i := time.Now(), 0; i < n; i++ {
+		_ = start
+	}
+}
+
+func backwardGoto(n int) { // want backwardGoto:`callsNow\(time.Now\)`
+	i := 0
+again: // want "time.Now should not be called inside loops; compute the value outside the loop"
+	_ = time.Now()
+	i++
+	if i < n {
+		goto again
+	}
+}
+
+func forwardGoto(skip bool) {
+	if skip {
+		goto done
+	}
+	_ = time.Now()
+done:
+}
+
+func sortCallback(xs []time.Time) {
+	slices.SortFunc(xs, func(a, b time.Time) int { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		return a.Compare(time.Now())
+	})
+	sort.Slice(xs, func(i, j int) bool { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		return xs[i].Before(time.Now())
+	})
+}
+
+func syncMapRange(m *sync.Map) {
+	m.Range(func(k, v any) bool { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		_ = time.Now()
+		return true
+	})
+}
+
+func onceCallback(once *sync.Once) {
+	once.Do(func() {
+		_ = time.Now()
+	})
+}
@@ -0,0 +1,28 @@
+package loopnowcategory
+
+import "time"
+
+func hoist(items []string) []time.Time {
+	var out []time.Time
+	for range items { // want `time.Now should not be called inside loops; compute the value outside the loop`
+		out = append(out, time.Now())
+	}
+	return out
+}
+
+func pollCondition(deadline time.Time) int { // want pollCondition:`callsNow\(time.Now\)`
+	n := 0
+	for time.Now().Before(deadline) { // want `time.Now should not be called inside loops; compute the value outside the loop`
+		n++
+	}
+	return n
+}
+
+func pollSleep(deadline time.Time) {
+	for { // want `time.Now should not be called inside loops; compute the value outside the loop`
+		if time.Now().After(deadline) {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,9 @@
+package notests
+
+import "time"
+
+func poll(n int) {
+	for i := 0; i < n; i++ { // want "time.Now should not be called inside loops; compute the value outside the loop"
+		_ = time.Now()
+	}
+}
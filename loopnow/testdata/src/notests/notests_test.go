@@ -0,0 +1,12 @@
+package notests
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		_ = time.Now()
+	}
+}
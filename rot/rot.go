@@ -2,33 +2,45 @@ package rot
 
 import (
 	"flag"
+	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
 
+	"github.com/ribice/smgt/internal/astutil"
+	"github.com/ribice/smgt/internal/config"
+	"github.com/ribice/smgt/internal/directive"
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/edge"
+	"golang.org/x/tools/go/ast/inspector"
 )
 
 func NewAnalyzer() *analysis.Analyzer {
 	return &analysis.Analyzer{
-		Name:  "rot",
-		Doc:   "Makes sure that a variable is defined right before it is used.",
-		Run:   run,
-		Flags: flag.FlagSet{},
+		Name:       "rot",
+		Doc:        "Makes sure that a variable is defined right before it is used.",
+		URL:        "https://github.com/ribice/smgt/blob/main/rot/README.md#rot-analyzer",
+		Run:        run,
+		Flags:      flag.FlagSet{},
+		Requires:   []*analysis.Analyzer{inspect.Analyzer},
+		ResultType: directive.ResultType,
 	}
 }
 
 func run(pass *analysis.Pass) (any, error) {
-	for _, file := range pass.Files {
-		for _, decl := range file.Decls {
-			fn, ok := decl.(*ast.FuncDecl)
-			if !ok || fn.Body == nil {
-				continue
-			}
-			analyzeFunction(pass, fn)
+	pass, usage, err := config.Apply(pass, nil)
+	if err != nil || !usage.Ran {
+		return usage, err
+	}
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	for cur := range inspect.Root().Preorder((*ast.FuncDecl)(nil)) {
+		if cur.Node().(*ast.FuncDecl).Body == nil {
+			continue
 		}
+		analyzeFunction(pass, cur)
 	}
-	return nil, nil
+	return usage, nil
 }
 
 type blockCtx struct {
@@ -83,29 +95,30 @@ func (ctx *blockCtx) stmtsInRange(start, end int) []ast.Stmt {
 
 type analyzer struct {
 	pass       *analysis.Pass
-	parents    map[ast.Node]ast.Node
+	body       inspector.Cursor
 	stmtInfo   map[ast.Stmt]*stmtInfo
 	synthInfo  map[ast.Stmt]*stmtInfo
 	decls      map[types.Object]*declInfo
 	seen       map[types.Object]bool
-	violations map[types.Object]bool
+	violations map[types.Object]inspector.Cursor
 	forPost    map[ast.Stmt]struct{}
 	caseBlocks map[*ast.CaseClause]*blockCtx
 }
 
-func analyzeFunction(pass *analysis.Pass, fn *ast.FuncDecl) {
-	parents := buildParents(fn.Body)
+func analyzeFunction(pass *analysis.Pass, cur inspector.Cursor) {
+	fn := cur.Node().(*ast.FuncDecl)
+	body := cur.ChildAt(edge.FuncDecl_Body, -1)
 	builder := newContextBuilder()
 	builder.buildBlock(fn.Body, nil, nil)
 
 	a := &analyzer{
 		pass:       pass,
-		parents:    parents,
+		body:       body,
 		stmtInfo:   builder.stmtInfo,
 		synthInfo:  builder.synthInfo,
 		decls:      make(map[types.Object]*declInfo),
 		seen:       make(map[types.Object]bool),
-		violations: make(map[types.Object]bool),
+		violations: make(map[types.Object]inspector.Cursor),
 		forPost:    builder.forPost,
 		caseBlocks: builder.caseBlocks,
 	}
@@ -114,16 +127,52 @@ func analyzeFunction(pass *analysis.Pass, fn *ast.FuncDecl) {
 	if len(a.decls) == 0 {
 		return
 	}
-	a.inspectUses(fn.Body)
+	a.inspectUses()
 
 	for obj, decl := range a.decls {
-		if !a.violations[obj] {
+		use, ok := a.violations[obj]
+		if !ok {
 			continue
 		}
-		pass.Reportf(decl.pos, "variable %s should be declared right before it is used", decl.name)
+		category := "declared-early"
+		if a.narrowable(decl, use) {
+			category = "narrow-scope"
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:      decl.pos,
+			Category: category,
+			Message:  fmt.Sprintf("variable %s should be declared right before it is used", decl.name),
+			Related: []analysis.RelatedInformation{{
+				Pos:     use.Node().Pos(),
+				End:     use.Node().End(),
+				Message: "first used here",
+			}},
+		})
 	}
 }
 
+// narrowable reports whether every use of decl lies in one statement
+// nested below the declaring block, so the declaration can move into it.
+func (a *analyzer) narrowable(decl *declInfo, use inspector.Cursor) bool {
+	_, info := a.enclosingStmt(use)
+	if info == nil || info.block == decl.block {
+		return false
+	}
+	block := info.block
+	for block != nil && block.parent != decl.block {
+		block = block.parent
+	}
+	if block == nil || block.owner == nil {
+		return false
+	}
+	for cur := range a.body.Preorder((*ast.Ident)(nil)) {
+		if a.pass.TypesInfo.Uses[cur.Node().(*ast.Ident)] == decl.obj && !astutil.Within(cur, block.owner) {
+			return false
+		}
+	}
+	return true
+}
+
 func (a *analyzer) collectDecls(node ast.Node) {
 	ast.Inspect(node, func(n ast.Node) bool {
 		switch stmt := n.(type) {
@@ -225,7 +274,7 @@ func (a *analyzer) recordDeclFromAssign(ident *ast.Ident, stmt *ast.AssignStmt)
 	}
 	obj := a.pass.TypesInfo.Defs[ident]
 	if obj == nil {
-		if !a.isTypeSwitchAssign(stmt) {
+		if !isTypeSwitchAssign(stmt) {
 			return
 		}
 		obj = a.pass.TypesInfo.ObjectOf(ident)
@@ -233,73 +282,68 @@ func (a *analyzer) recordDeclFromAssign(ident *ast.Ident, stmt *ast.AssignStmt)
 	a.recordDeclWithObject(ident, stmt, obj)
 }
 
-func (a *analyzer) isTypeSwitchAssign(assign *ast.AssignStmt) bool {
-	parent, ok := a.parents[assign]
-	if !ok {
-		return false
-	}
-	ts, ok := parent.(*ast.TypeSwitchStmt)
-	if !ok {
+// isTypeSwitchAssign reports whether assign is the x := y.(type) guard of
+// a type switch, the only place a type assertion has no type.
+func isTypeSwitchAssign(assign *ast.AssignStmt) bool {
+	if len(assign.Rhs) != 1 {
 		return false
 	}
-	return ts.Assign == assign
+	assert, ok := assign.Rhs[0].(*ast.TypeAssertExpr)
+	return ok && assert.Type == nil
 }
 
-func (a *analyzer) inspectUses(node ast.Node) {
-	ast.Inspect(node, func(n ast.Node) bool {
-		ident, ok := n.(*ast.Ident)
-		if !ok {
-			return true
-		}
+func (a *analyzer) inspectUses() {
+	for cur := range a.body.Preorder((*ast.Ident)(nil)) {
+		ident := cur.Node().(*ast.Ident)
 		if ident.Name == "_" {
-			return true
+			continue
 		}
 		if def := a.pass.TypesInfo.Defs[ident]; def != nil {
-			return true
+			continue
 		}
 		obj := a.pass.TypesInfo.ObjectOf(ident)
 		if obj == nil {
-			return true
+			continue
 		}
 		decl, ok := a.decls[obj]
 		if !ok {
-			return true
+			continue
 		}
 		if a.seen[obj] {
-			return true
+			continue
 		}
-		stmt, info := a.enclosingStmt(ident)
+		stmt, info := a.enclosingStmt(cur)
 		if stmt == nil || info == nil {
 			a.seen[obj] = true
-			return true
+			continue
 		}
 		if _, ok := stmt.(*ast.ReturnStmt); ok {
 			a.seen[obj] = true
-			return true
+			continue
 		}
 		if _, skip := a.forPost[stmt]; skip {
-			return true
+			continue
 		}
-		if !a.pathOK(decl, stmt, info, ident) {
-			a.violations[obj] = true
+		if !a.pathOK(decl, stmt, info, cur) {
+			a.violations[obj] = cur
 		}
 		a.seen[obj] = true
-		return true
-	})
+	}
 }
 
-func (a *analyzer) enclosingStmt(node ast.Node) (ast.Stmt, *stmtInfo) {
-	for n := node; n != nil; n = a.parents[n] {
-		if stmt, ok := n.(ast.Stmt); ok {
-			if info := a.contextInfo(stmt); info != nil {
-				return stmt, info
-			}
-		}
+// enclosingStmt returns the innermost statement at or above cur that the
+// context builder placed in a block.
+func (a *analyzer) enclosingStmt(cur inspector.Cursor) (ast.Stmt, *stmtInfo) {
+	stmt, ok := astutil.EnclosingStmt(cur, func(stmt ast.Stmt) bool {
+		return a.contextInfo(stmt) != nil
+	})
+	if !ok {
+		return nil, nil
 	}
-	return nil, nil
+	return stmt.Node().(ast.Stmt), a.contextInfo(stmt.Node().(ast.Stmt))
 }
 
-func (a *analyzer) pathOK(decl *declInfo, stmt ast.Stmt, info *stmtInfo, ident *ast.Ident) bool {
+func (a *analyzer) pathOK(decl *declInfo, stmt ast.Stmt, info *stmtInfo, use inspector.Cursor) bool {
 	block := info.block
 	idx := info.index
 	for {
@@ -320,7 +364,7 @@ func (a *analyzer) pathOK(decl *declInfo, stmt ast.Stmt, info *stmtInfo, ident *
 			}
 			// Special case: if the use is in a composite literal within an assignment,
 			// and all statements between are declarations, allow it
-			if a.isUseInCompositeLiteral(ident, stmt) && onlyDeclarationsBetween(block, decl.index, idx) {
+			if a.isUseInCompositeLiteral(use, stmt) && onlyDeclarationsBetween(block, decl.index, idx) {
 				return true
 			}
 			// Special case: if all statements between are declarations or error checks (any error checks),
@@ -359,7 +403,7 @@ func (a *analyzer) pathOK(decl *declInfo, stmt ast.Stmt, info *stmtInfo, ident *
 				return false
 			}
 			if decl != nil && decl.stmt != nil {
-				if parent, ok := a.parents[decl.stmt]; ok && parent == block.owner {
+				if block.owner.(*ast.IfStmt).Init == decl.stmt {
 					return false
 				}
 			}
@@ -1248,7 +1292,7 @@ func (a *analyzer) isGuardStatement(stmt ast.Stmt, decl *declInfo) bool {
 	if !a.conditionUsesGuardVar(ifStmt.Cond, guardObjs) {
 		return false
 	}
-	if guardBodyTerminates(ifStmt.Body) {
+	if astutil.BlockTerminates(ifStmt.Body) {
 		return true
 	}
 	if decl != nil && decl.obj != nil && a.blockUsesObject(ifStmt.Body, decl.obj) {
@@ -1336,37 +1380,6 @@ func (a *analyzer) conditionUsesGuardVar(expr ast.Expr, guardObjs map[types.Obje
 	return found
 }
 
-func guardBodyTerminates(body *ast.BlockStmt) bool {
-	if body == nil || len(body.List) == 0 {
-		return false
-	}
-	last := body.List[len(body.List)-1]
-	return statementTerminates(last)
-}
-
-func statementTerminates(stmt ast.Stmt) bool {
-	switch s := stmt.(type) {
-	case *ast.ReturnStmt:
-		return true
-	case *ast.BranchStmt:
-		return s.Tok == token.CONTINUE
-	case *ast.ExprStmt:
-		return callTerminates(s.X)
-	}
-	return false
-}
-
-func callTerminates(expr ast.Expr) bool {
-	call, ok := expr.(*ast.CallExpr)
-	if !ok {
-		return false
-	}
-	if ident, ok := call.Fun.(*ast.Ident); ok && ident.Name == "panic" {
-		return true
-	}
-	return false
-}
-
 func (a *analyzer) isErrorCheck(stmt ast.Stmt, decl *declInfo) bool {
 	// Get the declaration statement for the variable we're tracking
 	declStmt := decl.block.stmtAt(decl.index)
@@ -1497,7 +1510,7 @@ func (a *analyzer) isErrorCheckIf(ifStmt *ast.IfStmt, errObj types.Object) bool
 	return false
 }
 
-func (a *analyzer) isUseInCompositeLiteral(ident *ast.Ident, stmt ast.Stmt) bool {
+func (a *analyzer) isUseInCompositeLiteral(use inspector.Cursor, stmt ast.Stmt) bool {
 	// Check if the identifier is used within a composite literal
 	// (struct, slice, array, or map literal) that's part of an assignment statement
 	assignStmt, ok := stmt.(*ast.AssignStmt)
@@ -1506,12 +1519,12 @@ func (a *analyzer) isUseInCompositeLiteral(ident *ast.Ident, stmt ast.Stmt) bool
 	}
 
 	// Walk up from the identifier to find if it's within a composite literal
-	for node := ast.Node(ident); node != nil; {
-		switch n := node.(type) {
+	for cur := range use.Enclosing() {
+		switch n := cur.Node().(type) {
 		case *ast.CompositeLit:
 			// Found a composite literal, check if it's part of the assignment
 			// by checking if the composite literal is within the assignment's RHS
-			return a.isCompositeLitInAssign(assignStmt, n)
+			return a.isCompositeLitInAssign(assignStmt, cur)
 		case *ast.AssignStmt:
 			// If we hit an assignment before a composite literal, it's not in a composite literal
 			return false
@@ -1521,42 +1534,21 @@ func (a *analyzer) isUseInCompositeLiteral(ident *ast.Ident, stmt ast.Stmt) bool
 				return false
 			}
 		}
-		// Get parent node
-		parent, exists := a.parents[node]
-		if !exists {
-			break
-		}
-		node = parent
 	}
 
 	return false
 }
 
-func (a *analyzer) isCompositeLitInAssign(assign *ast.AssignStmt, compositeLit ast.Node) bool {
+func (a *analyzer) isCompositeLitInAssign(assign *ast.AssignStmt, compositeLit inspector.Cursor) bool {
 	// Check if the composite literal is part of the assignment's right-hand side
 	for _, rhs := range assign.Rhs {
-		if a.isNodeWithin(compositeLit, rhs) {
+		if astutil.Within(compositeLit, rhs) {
 			return true
 		}
 	}
 	return false
 }
 
-func (a *analyzer) isNodeWithin(child, parent ast.Node) bool {
-	// Check if child is within parent's subtree by walking up the parent chain from child
-	for node := child; node != nil; {
-		if node == parent {
-			return true
-		}
-		p, exists := a.parents[node]
-		if !exists {
-			break
-		}
-		node = p
-	}
-	return false
-}
-
 func onlyDeclarationsBetween(block *blockCtx, from, to int) bool {
 	if block == nil {
 		return false
@@ -1597,23 +1589,6 @@ func (a *analyzer) contextInfo(stmt ast.Stmt) *stmtInfo {
 	return a.synthInfo[stmt]
 }
 
-func buildParents(root ast.Node) map[ast.Node]ast.Node {
-	parents := make(map[ast.Node]ast.Node)
-	var stack []ast.Node
-	ast.Inspect(root, func(n ast.Node) bool {
-		if n != nil {
-			if len(stack) > 0 {
-				parents[n] = stack[len(stack)-1]
-			}
-			stack = append(stack, n)
-			return true
-		}
-		stack = stack[:len(stack)-1]
-		return false
-	})
-	return parents
-}
-
 type contextBuilder struct {
 	stmtInfo   map[ast.Stmt]*stmtInfo
 	synthInfo  map[ast.Stmt]*stmtInfo
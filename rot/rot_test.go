@@ -3,6 +3,7 @@ package rot
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"golang.org/x/tools/go/analysis/analysistest"
@@ -17,3 +18,25 @@ func TestAll(t *testing.T) {
 	testdata := filepath.Join(wd, "testdata")
 	analysistest.Run(t, testdata, NewAnalyzer(), "rot")
 }
+
+func TestCategories(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	want := map[string]string{
+		"inner": "narrow-scope",
+		"outer": "declared-early",
+		"value": "declared-early",
+	}
+	for _, result := range analysistest.Run(t, testdata, NewAnalyzer(), "rotcategory") {
+		for _, diag := range result.Diagnostics {
+			name := strings.Fields(diag.Message)[1]
+			if diag.Category != want[name] {
+				t.Errorf("%s: category = %q, want %q", diag.Message, diag.Category, want[name])
+			}
+		}
+	}
+}
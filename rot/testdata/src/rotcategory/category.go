@@ -0,0 +1,30 @@
+package rotcategory
+
+import "fmt"
+
+func narrow(cond bool, input string) {
+	var inner string // want `variable inner should be declared right before it is used`
+	fmt.Println("unrelated")
+	fmt.Println("unrelated")
+	if cond {
+		inner = input
+		fmt.Println(inner)
+	}
+}
+
+func early(cond bool, input string) {
+	outer := input // want `variable outer should be declared right before it is used`
+	fmt.Println("unrelated")
+	fmt.Println("unrelated")
+	if cond {
+		fmt.Println(outer)
+	}
+	fmt.Println(outer)
+}
+
+func late(input string) {
+	value := input // want `variable value should be declared right before it is used`
+	fmt.Println("unrelated")
+	fmt.Println("unrelated")
+	fmt.Println(value)
+}
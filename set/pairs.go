@@ -0,0 +1,196 @@
+package set
+
+import (
+	"go/ast"
+	"go/types"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/ast/edge"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// structSet records how a map[K]struct{} variable is used, to find pairs
+// of sets that together emulate a map[K]bool.
+type structSet struct {
+	obj *types.Var
+	// writes and deletes hold the key of each insertion and deletion with
+	// the statement list it appears in.
+	writes  []keyedStmt
+	deletes []keyedStmt
+	// reads holds the key of each lookup with its enclosing function.
+	reads []keyedStmt
+	// other records a use the pair check does not understand, such as
+	// passing the set to a function or filling it from a literal.
+	other bool
+}
+
+type keyedStmt struct {
+	key   string
+	scope ast.Node
+}
+
+// reportPairs reports two map[K]struct{} sets in the same scope where
+// adding a key to one always deletes it from the other, and every lookup
+// in one is matched by a lookup of the same key in the other. Such a pair
+// is a map[K]bool, or a map to an enum when there are more states.
+func reportPairs(pass *analysis.Pass, inspect *inspector.Inspector) {
+	sets := make(map[*types.Var]*structSet)
+	for cur := range inspect.Root().Preorder((*ast.Ident)(nil)) {
+		ident := cur.Node().(*ast.Ident)
+		v, ok := pass.TypesInfo.ObjectOf(ident).(*types.Var)
+		if !ok || v.Pkg() != pass.Pkg || v.IsField() || !isStructSet(v.Type()) {
+			continue
+		}
+		set := sets[v]
+		if set == nil {
+			set = &structSet{obj: v}
+			sets[v] = set
+		}
+		set.use(pass, cur)
+	}
+
+	var candidates []*structSet
+	for _, set := range sets {
+		if !set.other && len(set.writes) > 0 && len(set.reads) > 0 {
+			candidates = append(candidates, set)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].obj.Pos() < candidates[j].obj.Pos()
+	})
+	paired := make(map[*structSet]bool)
+	for i, x := range candidates {
+		for _, y := range candidates[i+1:] {
+			if paired[x] || paired[y] || !exclusivePair(x, y) {
+				continue
+			}
+			paired[x], paired[y] = true, true
+			key := types.TypeString(x.obj.Type().Underlying().(*types.Map).Key(), types.RelativeTo(pass.Pkg))
+			pass.Report(analysis.Diagnostic{
+				Pos:      x.obj.Pos(),
+				Category: "pairs",
+				Message:  "map[" + key + "]struct{} sets " + x.obj.Name() + " and " + y.obj.Name() + " are mutually exclusive and always checked together; use a single map[" + key + "]bool, or a map to an enum type",
+				Related: []analysis.RelatedInformation{{
+					Pos:     y.obj.Pos(),
+					Message: y.obj.Name() + " declared here",
+				}},
+			})
+		}
+	}
+}
+
+func (s *structSet) use(pass *analysis.Pass, cur inspector.Cursor) {
+	kind, index := cur.ParentEdge()
+	parent := cur.Parent()
+	switch kind {
+	case edge.ValueSpec_Names:
+		spec := parent.Node().(*ast.ValueSpec)
+		if len(spec.Values) > index {
+			s.assigned(pass, spec.Values[index])
+		}
+	case edge.AssignStmt_Lhs:
+		assign := parent.Node().(*ast.AssignStmt)
+		if len(assign.Rhs) == len(assign.Lhs) {
+			s.assigned(pass, assign.Rhs[index])
+		} else {
+			s.other = true
+		}
+	case edge.IndexExpr_X:
+		expr := parent.Node().(*ast.IndexExpr)
+		key := types.ExprString(expr.Index)
+		if kind, _ := parent.ParentEdge(); kind == edge.AssignStmt_Lhs {
+			s.writes = append(s.writes, keyedStmt{key, parent.Parent().Parent().Node()})
+			return
+		}
+		s.reads = append(s.reads, keyedStmt{key, enclosingFunc(parent)})
+	case edge.CallExpr_Args:
+		call := parent.Node().(*ast.CallExpr)
+		switch builtinName(pass, call) {
+		case "delete":
+			if stmt, _ := parent.ParentEdge(); stmt == edge.ExprStmt_X && index == 0 {
+				s.deletes = append(s.deletes, keyedStmt{types.ExprString(call.Args[1]), parent.Parent().Parent().Node()})
+				return
+			}
+			s.other = true
+		case "len", "clear":
+		default:
+			s.other = true
+		}
+	case edge.RangeStmt_X, edge.BinaryExpr_X, edge.BinaryExpr_Y:
+	default:
+		s.other = true
+	}
+}
+
+// assigned accepts empty sets; a set filled from a literal or taken from
+// elsewhere may already share keys with its partner.
+func (s *structSet) assigned(pass *analysis.Pass, expr ast.Expr) {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.CompositeLit:
+		if len(e.Elts) == 0 {
+			return
+		}
+	case *ast.CallExpr:
+		if builtinName(pass, e) == "make" {
+			return
+		}
+	}
+	s.other = true
+}
+
+// exclusivePair reports whether every insertion into one set deletes the
+// same key from the other in the same statement list, and every lookup in
+// one is matched by a lookup of the same key in the other in the same
+// function.
+func exclusivePair(x, y *structSet) bool {
+	if x.obj.Parent() != y.obj.Parent() || !types.Identical(x.obj.Type(), y.obj.Type()) {
+		return false
+	}
+	return covered(x.writes, y.deletes) && covered(y.writes, x.deletes) &&
+		covered(x.reads, y.reads) && covered(y.reads, x.reads)
+}
+
+func covered(uses, by []keyedStmt) bool {
+	for _, u := range uses {
+		found := false
+		for _, b := range by {
+			if b == u {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func enclosingFunc(cur inspector.Cursor) ast.Node {
+	for fn := range cur.Enclosing((*ast.FuncDecl)(nil), (*ast.FuncLit)(nil)) {
+		return fn.Node()
+	}
+	return nil
+}
+
+func builtinName(pass *analysis.Pass, call *ast.CallExpr) string {
+	ident, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	if _, ok := pass.TypesInfo.Uses[ident].(*types.Builtin); !ok {
+		return ""
+	}
+	return ident.Name
+}
+
+// isStructSet reports whether typ is a map with struct{} values.
+func isStructSet(typ types.Type) bool {
+	m, ok := typ.Underlying().(*types.Map)
+	if !ok {
+		return false
+	}
+	elem, ok := m.Elem().Underlying().(*types.Struct)
+	return ok && elem.NumFields() == 0
+}
@@ -1,64 +1,708 @@
 package set
 
 import (
+	"cmp"
 	"flag"
+	"fmt"
 	"go/ast"
 	"go/constant"
 	"go/token"
 	"go/types"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/ribice/smgt/internal/config"
+	"github.com/ribice/smgt/internal/directive"
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/edge"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/types/typeutil"
 )
 
+type settings struct {
+	keys      keyFilter
+	estimate  bool
+	switchMax int
+	pairs     bool
+}
+
 func NewAnalyzer() *analysis.Analyzer {
-	return &analysis.Analyzer{
-		Name:  "set",
-		Doc:   "Detects map[string]bool values that are only assigned the constant true and recommends map[string]struct{} instead.",
-		Run:   run,
-		Flags: flag.FlagSet{},
+	a := &analysis.Analyzer{
+		Name:       "set",
+		Doc:        "Detects map[K]bool values that are only assigned the constant true and recommends map[K]struct{} instead.",
+		URL:        "https://github.com/ribice/smgt/blob/main/set/README.md#set-analyzer",
+		Run:        run,
+		Flags:      flag.FlagSet{},
+		ResultType: directive.ResultType,
+		Requires:   []*analysis.Analyzer{inspect.Analyzer},
+		FactTypes:  []analysis.Fact{new(flowFact), new(setFact)},
 	}
+	new(settings).register(&a.Flags)
+	return a
+}
+
+// register resets s to the defaults and binds it to fs.
+func (s *settings) register(fs *flag.FlagSet) {
+	*s = settings{}
+	fs.Var(&s.keys, "keys", "comma-separated key types to check, such as string,int64,example.com/pkg.ID; a named type also matches its underlying type's name; empty checks every key type")
+	fs.BoolVar(&s.estimate, "estimate", false, "estimate the memory each finding saves and summarise it per package")
+	fs.IntVar(&s.switchMax, "switch-max", 8, "suggest a switch for package-level set literals with fewer entries than this that never change after initialisation; 0 disables")
+	fs.BoolVar(&s.pairs, "pairs", false, "also report pairs of map[K]struct{} sets that are mutually exclusive and always checked together, which a single map[K]bool expresses")
+}
+
+// keyFilter is a flag.Value restricting the map key types that are checked.
+type keyFilter map[string]bool
+
+func (f keyFilter) String() string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (f *keyFilter) Set(value string) error {
+	*f = make(keyFilter)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		(*f)[name] = true
+	}
+	return nil
+}
+
+func (f keyFilter) allows(key types.Type) bool {
+	if len(f) == 0 {
+		return true
+	}
+	return f[types.TypeString(key, nil)] || f[types.TypeString(key.Underlying(), nil)]
 }
 
 type mapUsage struct {
 	onlyTrue bool
 	sawWrite bool
+	// valueRead records a read that observes the stored bool rather than
+	// key presence, such as v, ok := m[k] or range values; a struct{} set
+	// could not answer it.
+	valueRead bool
+	// escaped records that the map reaches, or comes from, code the
+	// analyzer cannot follow, so writes and reads elsewhere are unknown.
+	escaped bool
+	// returned maps and parameters belong to callers, which are reported
+	// instead through the function's flowFact.
+	returned bool
+	param    bool
+	// shared usages cover every variable, field and value of a named map
+	// type, and are reported once at the type. Roles of single variables,
+	// such as being a parameter, do not matter for them.
+	shared bool
+	// imported usages belong to a type or field another package uses as a
+	// set; conflicts holds the uses here that contradict it.
+	imported  bool
+	conflicts []token.Pos
+	// fixable stays true while every declaration, write and read of the map
+	// has a mechanical struct{} equivalent, collected in edits.
+	fixable bool
+	edits   []analysis.TextEdit
+	// literals counts the entries of each composite literal of the map;
+	// grows records writes that add entries at run time.
+	literals map[*ast.CompositeLit]int
+	grows    bool
+	// uses holds every use of the map other than its declaration.
+	uses []inspector.Cursor
+}
+
+func newUsage() *mapUsage {
+	return &mapUsage{onlyTrue: true, fixable: true}
+}
+
+func (u *mapUsage) writeOther(node ast.Node) {
+	u.onlyTrue = false
+	u.conflicts = append(u.conflicts, node.Pos())
+}
+
+func (u *mapUsage) readValue(node ast.Node) {
+	u.valueRead = true
+	u.conflicts = append(u.conflicts, node.Pos())
+}
+
+func (u *mapUsage) isSet() bool {
+	if !u.onlyTrue || !u.sawWrite || u.valueRead || u.escaped || u.imported {
+		return false
+	}
+	return u.shared || !u.returned && !u.param
+}
+
+func (u *mapUsage) flow() mapFlow {
+	return mapFlow{
+		WritesTrue:  u.sawWrite && u.onlyTrue,
+		WritesOther: !u.onlyTrue,
+		ReadsValue:  u.valueRead,
+		Escapes:     u.escaped,
+	}
+}
+
+// merge folds what happens to the map elsewhere into u and reports whether
+// u changed.
+func (u *mapUsage) merge(f mapFlow) bool {
+	before := *u
+	if f.WritesTrue || f.WritesOther {
+		u.sawWrite = true
+		u.grows = true
+	}
+	if f.WritesOther {
+		u.onlyTrue = false
+	}
+	if f.ReadsValue {
+		u.valueRead = true
+	}
+	if f.Escapes {
+		u.escaped = true
+	}
+	return u.sawWrite != before.sawWrite || u.onlyTrue != before.onlyTrue ||
+		u.valueRead != before.valueRead || u.escaped != before.escaped
+}
+
+// mapFlow summarises what a function does with a map[K]bool it is given
+// or returns.
+type mapFlow struct {
+	WritesTrue  bool
+	WritesOther bool
+	ReadsValue  bool
+	Escapes     bool
+}
+
+func (f mapFlow) String() string {
+	var parts []string
+	if f.WritesTrue {
+		parts = append(parts, "writes true")
+	}
+	if f.WritesOther {
+		parts = append(parts, "writes other")
+	}
+	if f.ReadsValue {
+		parts = append(parts, "reads value")
+	}
+	if f.Escapes {
+		parts = append(parts, "escapes")
+	}
+	if len(parts) == 0 {
+		return "unused"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// flowFact is exported for functions with map[K]bool parameters or
+// results, keyed by parameter and result index, so callers in other
+// packages can follow maps through them.
+type flowFact struct {
+	Params  map[int]mapFlow
+	Results map[int]mapFlow
+}
+
+func (*flowFact) AFact() {}
+
+func (f *flowFact) String() string {
+	var parts []string
+	for _, i := range sortedKeys(f.Params) {
+		parts = append(parts, fmt.Sprintf("param %d: %s", i, f.Params[i]))
+	}
+	for _, i := range sortedKeys(f.Results) {
+		parts = append(parts, fmt.Sprintf("result %d: %s", i, f.Results[i]))
+	}
+	return "mapFlow(" + strings.Join(parts, "; ") + ")"
+}
+
+func sortedKeys(m map[int]mapFlow) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// link makes from's writes, reads and escapes part of to, for a map that
+// is passed to a parameter or returned from a function.
+type link struct {
+	from, to *mapUsage
+}
+
+// unify links two maps both ways. maps.Copy and maps.Clone require both
+// sides to have the same type, so either both are sets or neither is.
+func (a *analyzer) unify(x, y *mapUsage) {
+	a.links = append(a.links, link{from: x, to: y}, link{from: y, to: x})
+}
+
+func (u *mapUsage) edit(node ast.Node, text string) {
+	u.edits = append(u.edits, analysis.TextEdit{
+		Pos:     node.Pos(),
+		End:     node.End(),
+		NewText: []byte(text),
+	})
 }
 
 func run(pass *analysis.Pass) (any, error) {
-	usages := collectCandidates(pass)
-	if len(usages) == 0 {
-		return nil, nil
+	s := new(settings)
+	pass, usage, err := config.Apply(pass, s.register)
+	if err != nil || !usage.Ran {
+		return usage, err
 	}
+	_, err = s.run(pass)
+	return usage, err
+}
 
+func (s *settings) run(pass *analysis.Pass) (any, error) {
 	a := &analyzer{
-		pass:   pass,
-		usages: usages,
+		pass:    pass,
+		usages:  make(map[types.Object]*mapUsage),
+		files:   make(map[string][]byte),
+		results: make(map[*types.Func]map[int]*mapUsage),
+		bodies:  make(map[*types.Func]bool),
 	}
+	a.collectCandidates()
 
-	for _, file := range pass.Files {
-		ast.Inspect(file, a.inspect)
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	filter := []ast.Node{
+		(*ast.FuncDecl)(nil),
+		(*ast.TypeSpec)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.ValueSpec)(nil),
+		(*ast.CompositeLit)(nil),
+		(*ast.ReturnStmt)(nil),
+		(*ast.Ident)(nil),
+	}
+	for cur := range inspect.Root().Preorder(filter...) {
+		switch n := cur.Node().(type) {
+		case *ast.FuncDecl:
+			if fn, ok := pass.TypesInfo.Defs[n.Name].(*types.Func); ok && n.Body != nil {
+				a.bodies[fn] = true
+			}
+		case *ast.TypeSpec:
+			a.handleTypeSpec(n)
+		case *ast.AssignStmt:
+			a.handleAssignStmt(n)
+		case *ast.ValueSpec:
+			a.handleValueSpec(n)
+		case *ast.CompositeLit:
+			// Literals of a named set type may appear anywhere, not just
+			// where they are assigned to a variable.
+			if tn := namedSet(pass.TypesInfo.TypeOf(n)); tn != nil {
+				if usage := a.typeUsage(tn); usage != nil {
+					a.handleAssignedValue(usage, n)
+				}
+			}
+			a.handleUnkeyedFields(n)
+		case *ast.ReturnStmt:
+			a.handleReturn(cur, n)
+		case *ast.Ident:
+			a.handleIdent(cur, n)
+		}
 	}
+	a.propagate()
+	a.exportFacts()
 
-	for obj, usage := range usages {
-		if usage.onlyTrue && usage.sawWrite {
-			pass.Reportf(obj.Pos(), "map[string]bool variable %s is used as a set; use map[string]struct{} instead", obj.Name())
+	var total estimateTotal
+	var conflicts []analysis.Diagnostic
+	// Report in source order, so that output is stable.
+	objs := slices.SortedFunc(maps.Keys(a.usages), func(x, y types.Object) int {
+		return cmp.Compare(x.Pos(), y.Pos())
+	})
+	for _, obj := range objs {
+		usage := a.usages[obj]
+		if usage == nil || !s.keys.allows(obj.Type().Underlying().(*types.Map).Key()) {
+			continue
+		}
+		if usage.shared && !isTypeName(obj) {
+			// Reported once at the type.
+			continue
+		}
+		if usage.imported {
+			conflicts = append(conflicts, a.conflicts(obj, usage)...)
+			continue
+		}
+		if !usage.isSet() {
+			continue
+		}
+		key := types.TypeString(obj.Type().Underlying().(*types.Map).Key(), types.RelativeTo(pass.Pkg))
+		if decl, lit := a.constantSet(obj, usage, s.switchMax); lit != nil {
+			a.reportConstantSet(obj, decl, lit, key)
+			continue
+		}
+		diag := analysis.Diagnostic{
+			Pos:      obj.Pos(),
+			Category: "bool-map",
+			Message:  fmt.Sprintf("map[%s]bool %s is used as a set; use map[%s]struct{} instead", key, describe(obj), key),
+		}
+		// Other packages may use an exported type or field in ways this
+		// pass cannot see, so changing it is left to the author.
+		exported := obj.Exported() && (isTypeName(obj) || isField(obj))
+		if usage.fixable && !exported {
+			diag.SuggestedFixes = []analysis.SuggestedFix{{
+				Message:   fmt.Sprintf("Use map[%s]struct{}", key),
+				TextEdits: sortedEdits(usage.edits),
+			}}
+		}
+		if s.estimate {
+			est := a.estimate(obj, usage)
+			diag.Message += " (" + est.String() + ")"
+			total.add(est)
+		}
+		pass.Report(diag)
+		if exported {
+			pass.ExportObjectFact(obj, new(setFact))
 		}
 	}
+	// Imported objects may share a position, so sort their conflicts by
+	// where they occur here.
+	slices.SortStableFunc(conflicts, func(x, y analysis.Diagnostic) int {
+		return cmp.Or(cmp.Compare(x.Pos, y.Pos), strings.Compare(x.Message, y.Message))
+	})
+	for _, diag := range conflicts {
+		pass.Report(diag)
+	}
+	if total.maps > 0 && len(pass.Files) > 0 {
+		pass.Report(analysis.Diagnostic{
+			Pos:      pass.Files[0].Name.Pos(),
+			Category: "estimate",
+			Message:  total.summary(pass.Pkg.Name()),
+		})
+	}
+	if s.pairs {
+		reportPairs(pass, inspect)
+	}
 
 	return nil, nil
 }
 
+// estimate is the memory a finding saves by storing struct{} instead of
+// bool. A map stores each key next to its value, so the saving per entry
+// is the difference in size of that pair after alignment and padding.
+type estimate struct {
+	perEntry  int64
+	entries   int
+	unbounded bool
+}
+
+func (a *analyzer) estimate(obj types.Object, usage *mapUsage) estimate {
+	sizes := a.pass.TypesSizes
+	if sizes == nil {
+		sizes = types.SizesFor("gc", "amd64")
+	}
+	key := obj.Type().Underlying().(*types.Map).Key()
+	slot := func(elem types.Type) int64 {
+		return sizes.Sizeof(types.NewStruct([]*types.Var{
+			types.NewField(token.NoPos, nil, "key", key, false),
+			types.NewField(token.NoPos, nil, "elem", elem, false),
+		}, nil))
+	}
+	est := estimate{
+		perEntry:  slot(types.Typ[types.Bool]) - slot(types.NewStruct(nil, nil)),
+		unbounded: usage.grows,
+	}
+	for _, n := range usage.literals {
+		est.entries += n
+	}
+	return est
+}
+
+func (e estimate) String() string {
+	if e.unbounded {
+		return fmt.Sprintf("estimated saving: %s per entry, unbounded as entries are added at run time", count(e.perEntry, "byte", "bytes"))
+	}
+	return fmt.Sprintf("estimated saving: %s for %s at %s each",
+		count(e.perEntry*int64(e.entries), "byte", "bytes"), count(int64(e.entries), "entry", "entries"), count(e.perEntry, "byte", "bytes"))
+}
+
+// count formats n followed by the singular or plural noun.
+func count(n int64, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return strconv.FormatInt(n, 10) + " " + plural
+}
+
+type estimateTotal struct {
+	maps, unbounded int
+	bytes, perEntry int64
+}
+
+func (t *estimateTotal) add(e estimate) {
+	t.maps++
+	if e.unbounded {
+		t.unbounded++
+		t.perEntry += e.perEntry
+		return
+	}
+	t.bytes += e.perEntry * int64(e.entries)
+}
+
+func (t estimateTotal) summary(pkg string) string {
+	return fmt.Sprintf("estimated saving for package %s: %s across %s, plus %s per entry across %s",
+		pkg, count(t.bytes, "byte", "bytes"), count(int64(t.maps-t.unbounded), "literal map", "literal maps"),
+		count(t.perEntry, "byte", "bytes"), count(int64(t.unbounded), "unbounded map", "unbounded maps"))
+}
+
+// constantSet returns the declaration and literal of a package-level set
+// that is never changed after initialisation and has fewer than max
+// constant keys, or nil.
+func (a *analyzer) constantSet(obj types.Object, usage *mapUsage, max int) (*ast.GenDecl, *ast.CompositeLit) {
+	if max <= 0 || usage.shared || usage.grows || len(usage.literals) != 1 || obj.Parent() != a.pass.Pkg.Scope() {
+		return nil, nil
+	}
+	var lit *ast.CompositeLit
+	for l := range usage.literals {
+		lit = l
+	}
+	if len(lit.Elts) == 0 || len(lit.Elts) >= max {
+		return nil, nil
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok || a.pass.TypesInfo.Types[kv.Key].Value == nil {
+			return nil, nil
+		}
+	}
+	// Only lookups and len leave the map as it was built; any other use,
+	// such as delete, clear, ranging or passing it on, may change it.
+	for _, use := range usage.uses {
+		switch kind, _ := use.ParentEdge(); kind {
+		case edge.IndexExpr_X:
+			switch kind, _ := use.Parent().ParentEdge(); kind {
+			case edge.AssignStmt_Lhs, edge.IncDecStmt_X, edge.UnaryExpr_X:
+				return nil, nil
+			}
+		case edge.CallExpr_Args:
+			if !a.isBuiltin(use.Parent().Node().(*ast.CallExpr), "len") {
+				return nil, nil
+			}
+		default:
+			return nil, nil
+		}
+	}
+	for _, file := range a.pass.Files {
+		for _, d := range file.Decls {
+			decl, ok := d.(*ast.GenDecl)
+			if !ok || decl.Tok != token.VAR || len(decl.Specs) != 1 {
+				continue
+			}
+			spec := decl.Specs[0].(*ast.ValueSpec)
+			if len(spec.Names) == 1 && spec.Names[0].Pos() == obj.Pos() && len(spec.Values) == 1 && spec.Values[0] == lit {
+				return decl, lit
+			}
+		}
+	}
+	return nil, nil
+}
+
+// reportConstantSet suggests replacing a small constant set with a switch
+// in a predicate function, which is faster than a map lookup and does not
+// allocate. The fix is offered when every use is a lookup or len.
+func (a *analyzer) reportConstantSet(obj types.Object, decl *ast.GenDecl, lit *ast.CompositeLit, key string) {
+	diag := analysis.Diagnostic{
+		Pos:      obj.Pos(),
+		Category: "switch",
+		Message:  fmt.Sprintf("map[%s]bool variable %s is a constant set of %s; a switch in a predicate function is faster and does not allocate", key, obj.Name(), count(int64(len(lit.Elts)), "entry", "entries")),
+	}
+	name := a.predicateName(obj)
+	var keys []string
+	for _, elt := range lit.Elts {
+		src, ok := a.source(elt.(*ast.KeyValueExpr).Key)
+		if !ok {
+			a.pass.Report(diag)
+			return
+		}
+		keys = append(keys, src)
+	}
+	fn := fmt.Sprintf("func %s(k %s) bool {\n\tswitch k {\n\tcase %s:\n\t\treturn true\n\t}\n\treturn false\n}", name, key, strings.Join(keys, ", "))
+	edits := []analysis.TextEdit{{Pos: decl.Pos(), End: decl.End(), NewText: []byte(fn)}}
+	for _, use := range a.usages[obj].uses {
+		more, ok := a.rewriteLookup(use, name, len(lit.Elts))
+		if !ok {
+			a.pass.Report(diag)
+			return
+		}
+		edits = append(edits, more...)
+	}
+	diag.SuggestedFixes = []analysis.SuggestedFix{{
+		Message:   fmt.Sprintf("Replace %s with %s", obj.Name(), name),
+		TextEdits: sortedEdits(edits),
+	}}
+	a.pass.Report(diag)
+}
+
+// rewriteLookup turns m[k] into name(k), _, ok := m[k] into ok := name(k)
+// and len(m) into the constant length n.
+func (a *analyzer) rewriteLookup(use inspector.Cursor, name string, n int) ([]analysis.TextEdit, bool) {
+	kind, _ := use.ParentEdge()
+	parent := use.Parent()
+	switch kind {
+	case edge.IndexExpr_X:
+		index := parent.Node().(*ast.IndexExpr)
+		key, ok := a.source(index.Index)
+		if !ok {
+			return nil, false
+		}
+		edits := []analysis.TextEdit{{Pos: index.Pos(), End: index.End(), NewText: []byte(name + "(" + key + ")")}}
+		// Drop the blank value of _, ok := m[k].
+		var blank, ok2 ast.Node
+		switch kind, _ := parent.ParentEdge(); kind {
+		case edge.AssignStmt_Rhs:
+			if assign := parent.Parent().Node().(*ast.AssignStmt); len(assign.Lhs) == 2 {
+				blank, ok2 = assign.Lhs[0], assign.Lhs[1]
+			}
+		case edge.ValueSpec_Values:
+			if spec := parent.Parent().Node().(*ast.ValueSpec); len(spec.Names) == 2 {
+				blank, ok2 = spec.Names[0], spec.Names[1]
+			}
+		}
+		if blank != nil {
+			edits = append(edits, analysis.TextEdit{Pos: blank.Pos(), End: ok2.Pos()})
+		}
+		return edits, true
+	case edge.CallExpr_Args:
+		call := parent.Node().(*ast.CallExpr)
+		if a.isBuiltin(call, "len") {
+			return []analysis.TextEdit{{Pos: call.Pos(), End: call.End(), NewText: []byte(strconv.Itoa(n))}}, true
+		}
+	}
+	return nil, false
+}
+
+// predicateName returns isName, numbered if that name is already taken
+// where the map is used.
+func (a *analyzer) predicateName(obj types.Object) string {
+	base := "is" + strings.ToUpper(obj.Name()[:1]) + obj.Name()[1:]
+	taken := func(name string) bool {
+		if a.pass.Pkg.Scope().Lookup(name) != nil {
+			return true
+		}
+		for _, use := range a.usages[obj].uses {
+			pos := use.Node().Pos()
+			if scope := a.pass.Pkg.Scope().Innermost(pos); scope != nil {
+				if _, found := scope.LookupParent(name, pos); found != nil {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	name := base
+	for i := 1; taken(name); i++ {
+		name = base + strconv.Itoa(i)
+	}
+	return name
+}
+
+// setFact marks exported named map types and struct fields that their
+// package reports as sets, so packages using them can point out the uses
+// that would break once the type changes.
+type setFact struct{}
+
+func (*setFact) AFact() {}
+
+func (*setFact) String() string { return "set" }
+
+// conflicts returns the uses of a type or field from another package that
+// contradict that package using it as a set.
+func (a *analyzer) conflicts(obj types.Object, usage *mapUsage) []analysis.Diagnostic {
+	sort.Slice(usage.conflicts, func(i, j int) bool {
+		return usage.conflicts[i] < usage.conflicts[j]
+	})
+	var diags []analysis.Diagnostic
+	for i, pos := range usage.conflicts {
+		if i > 0 && pos == usage.conflicts[i-1] {
+			continue
+		}
+		diags = append(diags, analysis.Diagnostic{
+			Pos:      pos,
+			Category: "conflict",
+			Message:  fmt.Sprintf("%s %s is used as a set by its package; this use relies on the stored bool values", kindOf(obj), qualifiedName(obj)),
+		})
+	}
+	return diags
+}
+
+// qualifiedName returns pkg.Name for obj, or pkg.Type.Field for a field
+// of a named struct type of its package.
+func qualifiedName(obj types.Object) string {
+	name := obj.Pkg().Name() + "."
+	if isField(obj) {
+		scope := obj.Pkg().Scope()
+		for _, n := range scope.Names() {
+			tn, ok := scope.Lookup(n).(*types.TypeName)
+			if !ok {
+				continue
+			}
+			if st, ok := tn.Type().Underlying().(*types.Struct); ok {
+				for field := range st.Fields() {
+					if field == obj {
+						return name + tn.Name() + "." + obj.Name()
+					}
+				}
+			}
+		}
+	}
+	return name + obj.Name()
+}
+
+func describe(obj types.Object) string {
+	return kindOf(obj) + " " + obj.Name()
+}
+
+func kindOf(obj types.Object) string {
+	switch {
+	case isField(obj):
+		return "field"
+	case isTypeName(obj):
+		return "type"
+	}
+	return "variable"
+}
+
+func isField(obj types.Object) bool {
+	v, ok := obj.(*types.Var)
+	return ok && v.IsField()
+}
+
+func isTypeName(obj types.Object) bool {
+	_, ok := obj.(*types.TypeName)
+	return ok
+}
+
 type analyzer struct {
 	pass   *analysis.Pass
 	usages map[types.Object]*mapUsage
+	files  map[string][]byte
+	// results summarises the maps returned by functions of this package,
+	// by result index; bodies holds the functions whose code is visible.
+	results map[*types.Func]map[int]*mapUsage
+	bodies  map[*types.Func]bool
+	links   []link
 }
 
-func collectCandidates(pass *analysis.Pass) map[types.Object]*mapUsage {
-	usages := make(map[types.Object]*mapUsage)
+// collectCandidates tracks every map[K]bool variable of the package.
+// Parameters and named results are tracked too, to summarise them in
+// facts, but never reported. Variables, fields and values of a named map
+// type share one usage under the type name. Exported package variables
+// may be written by other packages and are treated as escaped.
+func (a *analyzer) collectCandidates() {
+	pass := a.pass
 	for ident, obj := range pass.TypesInfo.Defs {
 		if obj == nil {
 			continue
 		}
+		if tn, ok := obj.(*types.TypeName); ok && namedSet(tn.Type()) == tn && tn.Pkg() == pass.Pkg {
+			a.typeUsage(tn)
+			continue
+		}
 		v, ok := obj.(*types.Var)
 		if !ok {
 			continue
@@ -66,29 +710,225 @@ func collectCandidates(pass *analysis.Pass) map[types.Object]*mapUsage {
 		if pass.Pkg != nil && v.Pkg() != pass.Pkg {
 			continue
 		}
-		if !isStringBoolMap(v.Type()) {
+		if !isBoolMap(v.Type()) {
 			continue
 		}
 		if !identIsValid(ident) {
 			continue
 		}
-		usages[obj] = &mapUsage{
-			onlyTrue: true,
+		if tn := namedSet(v.Type()); tn != nil {
+			if usage := a.typeUsage(tn); usage != nil {
+				a.usages[obj] = usage
+				continue
+			}
+		}
+		usage := newUsage()
+		if v.Exported() && v.Parent() == pass.Pkg.Scope() {
+			usage.escaped = true
 		}
+		a.usages[obj] = usage
 	}
-	return usages
 }
 
-func (a *analyzer) inspect(node ast.Node) bool {
-	switch n := node.(type) {
-	case *ast.AssignStmt:
-		a.handleAssignStmt(n)
-	case *ast.RangeStmt:
-		// no-op; included to avoid extra allocations in default branch
-	case *ast.ValueSpec:
-		a.handleValueSpec(n)
+// typeUsage returns the usage shared by all values of the named map type
+// tn. Types of other packages only have one when their package reported
+// them as a set.
+func (a *analyzer) typeUsage(tn *types.TypeName) *mapUsage {
+	if usage, ok := a.usages[tn]; ok {
+		return usage
+	}
+	var usage *mapUsage
+	if tn.Pkg() == a.pass.Pkg {
+		usage = newUsage()
+		usage.shared = true
+	} else if a.pass.ImportObjectFact(tn, new(setFact)) {
+		usage = newUsage()
+		usage.shared = true
+		usage.imported = true
+	}
+	a.usages[tn] = usage
+	return usage
+}
+
+// usage returns the usage tracking obj, if any. Fields of other packages
+// are looked up lazily, since only their uses appear in this package.
+func (a *analyzer) usage(obj types.Object) *mapUsage {
+	if obj == nil {
+		return nil
+	}
+	if usage, ok := a.usages[obj]; ok {
+		return usage
+	}
+	var usage *mapUsage
+	if v, ok := obj.(*types.Var); ok && v.IsField() && v.Pkg() != a.pass.Pkg && isBoolMap(v.Type()) {
+		if tn := namedSet(v.Type()); tn != nil {
+			usage = a.typeUsage(tn)
+		} else if a.pass.ImportObjectFact(v, new(setFact)) {
+			usage = newUsage()
+			usage.imported = true
+		}
+	}
+	a.usages[obj] = usage
+	return usage
+}
+
+// namedSet returns the type name of a named map[K]bool type. Every
+// instantiation of a generic type such as Set[K] shares the name of its
+// declaration.
+func namedSet(typ types.Type) *types.TypeName {
+	named, ok := types.Unalias(typ).(*types.Named)
+	if !ok || !isBoolMap(named.Origin()) {
+		return nil
+	}
+	return named.Origin().Obj()
+}
+
+// propagate applies the links between maps, parameters and results until
+// nothing changes. Summaries only ever gain writes, reads and escapes, so
+// this terminates even for recursive functions.
+func (a *analyzer) propagate() {
+	for changed := true; changed; {
+		changed = false
+		for _, l := range a.links {
+			if l.to.merge(l.from.flow()) {
+				changed = true
+			}
+		}
+	}
+}
+
+func (a *analyzer) exportFacts() {
+	for fn := range a.bodies {
+		sig := fn.Signature()
+		fact := &flowFact{}
+		for i := range sig.Params().Len() {
+			if usage := a.usages[sig.Params().At(i)]; usage != nil {
+				if fact.Params == nil {
+					fact.Params = make(map[int]mapFlow)
+				}
+				fact.Params[i] = usage.flow()
+			}
+		}
+		for i := range sig.Results().Len() {
+			if !isBoolMap(sig.Results().At(i).Type()) {
+				continue
+			}
+			if fact.Results == nil {
+				fact.Results = make(map[int]mapFlow)
+			}
+			fact.Results[i] = a.result(fn, i).flow()
+		}
+		if fact.Params != nil || fact.Results != nil {
+			a.pass.ExportObjectFact(fn, fact)
+		}
 	}
-	return true
+}
+
+// result returns the summary of the maps fn returns as result i.
+func (a *analyzer) result(fn *types.Func, i int) *mapUsage {
+	if a.results[fn] == nil {
+		a.results[fn] = make(map[int]*mapUsage)
+	}
+	if a.results[fn][i] == nil {
+		a.results[fn][i] = newUsage()
+	}
+	return a.results[fn][i]
+}
+
+// calleeParam returns the summary for the map passed as argument i of a
+// call, or nil when the callee cannot be followed.
+func (a *analyzer) calleeParam(call *ast.CallExpr, i int) *mapUsage {
+	fn := a.callee(call)
+	if fn == nil {
+		return nil
+	}
+	if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+		if s := a.pass.TypesInfo.Selections[sel]; s != nil && s.Kind() == types.MethodExpr {
+			// The receiver is the first argument of T.Method(x, ...).
+			i--
+		}
+	}
+	params := fn.Signature().Params()
+	if i < 0 || i >= params.Len() || fn.Signature().Variadic() && i >= params.Len()-1 {
+		return nil
+	}
+	if fn.Pkg() == a.pass.Pkg {
+		if !a.bodies[fn] {
+			return nil
+		}
+		return a.usages[params.At(i)]
+	}
+	var fact flowFact
+	if !a.pass.ImportObjectFact(fn, &fact) {
+		return nil
+	}
+	f, ok := fact.Params[i]
+	if !ok {
+		return nil
+	}
+	return fixedUsage(f)
+}
+
+// calleeResult returns the summary for result i of a call, or nil when the
+// callee cannot be followed.
+func (a *analyzer) calleeResult(call *ast.CallExpr, i int) *mapUsage {
+	fn := a.callee(call)
+	if fn == nil {
+		return nil
+	}
+	if fn.Pkg() == a.pass.Pkg {
+		if !a.bodies[fn] {
+			return nil
+		}
+		return a.result(fn, i)
+	}
+	var fact flowFact
+	if !a.pass.ImportObjectFact(fn, &fact) {
+		return nil
+	}
+	f, ok := fact.Results[i]
+	if !ok {
+		return nil
+	}
+	return fixedUsage(f)
+}
+
+func (a *analyzer) callee(call *ast.CallExpr) *types.Func {
+	fn := typeutil.StaticCallee(a.pass.TypesInfo, call)
+	if fn == nil {
+		return nil
+	}
+	return fn.Origin()
+}
+
+// fixedUsage turns an imported summary into a usage that links can read
+// from.
+func fixedUsage(f mapFlow) *mapUsage {
+	u := newUsage()
+	u.merge(f)
+	return u
+}
+
+// passTo links a map passed as argument i of call to the callee's
+// parameter.
+func (a *analyzer) passTo(usage *mapUsage, call *ast.CallExpr, i int) {
+	param := a.calleeParam(call, i)
+	if param == nil {
+		usage.escaped = true
+		return
+	}
+	a.links = append(a.links, link{from: param, to: usage})
+}
+
+// receive links a map assigned from result i of call to the callee's
+// result summary.
+func (a *analyzer) receive(usage *mapUsage, call *ast.CallExpr, i int) {
+	result := a.calleeResult(call, i)
+	if result == nil {
+		usage.escaped = true
+		return
+	}
+	a.links = append(a.links, link{from: result, to: usage})
 }
 
 func (a *analyzer) handleAssignStmt(stmt *ast.AssignStmt) {
@@ -102,7 +942,7 @@ func (a *analyzer) handleAssignStmt(stmt *ast.AssignStmt) {
 		if target == nil {
 			continue
 		}
-		usage := a.usages[target]
+		usage := a.usage(target)
 		if usage == nil {
 			continue
 		}
@@ -110,15 +950,18 @@ func (a *analyzer) handleAssignStmt(stmt *ast.AssignStmt) {
 			// map indexes cannot appear on the left-hand side of :=
 			continue
 		}
+		usage.grows = true
 		value := a.valueForIndex(stmt, i)
 		if value == nil {
-			usage.onlyTrue = false
+			usage.writeOther(lhs)
 			continue
 		}
 		usage.sawWrite = true
 		if !a.isConstTrue(value) {
-			usage.onlyTrue = false
+			usage.writeOther(value)
+			continue
 		}
+		usage.edit(value, "struct{}{}")
 	}
 
 	// Handle direct assignments of composite literals.
@@ -127,18 +970,38 @@ func (a *analyzer) handleAssignStmt(stmt *ast.AssignStmt) {
 		if obj == nil {
 			continue
 		}
-		usage := a.usages[obj]
+		usage := a.usage(obj)
 		if usage == nil {
 			continue
 		}
 		value := a.valueForIndex(stmt, i)
 		if value == nil {
+			usage.escaped = true
+			usage.fixable = false
+			continue
+		}
+		if len(stmt.Lhs) != len(stmt.Rhs) {
+			a.handleMultiValue(usage, value, i)
 			continue
 		}
 		a.handleAssignedValue(usage, value)
 	}
 }
 
+// handleTypeSpec records the edit to a named map type's declaration.
+func (a *analyzer) handleTypeSpec(spec *ast.TypeSpec) {
+	usage := a.usage(a.pass.TypesInfo.Defs[spec.Name])
+	if usage == nil {
+		return
+	}
+	if m, ok := spec.Type.(*ast.MapType); ok {
+		usage.edit(m.Value, "struct{}")
+		return
+	}
+	// type T U cannot change without changing U.
+	usage.fixable = false
+}
+
 func (a *analyzer) handleValueSpec(spec *ast.ValueSpec) {
 	for i, name := range spec.Names {
 		obj := a.pass.TypesInfo.Defs[name]
@@ -149,25 +1012,34 @@ func (a *analyzer) handleValueSpec(spec *ast.ValueSpec) {
 		if usage == nil {
 			continue
 		}
+		if spec.Type != nil {
+			a.handleDeclaredType(usage, spec.Type, len(spec.Names))
+		}
 		if len(spec.Values) == 0 {
 			continue
 		}
-		value := spec.Values[0]
-		if len(spec.Values) == len(spec.Names) {
-			value = spec.Values[i]
+		if len(spec.Values) != len(spec.Names) {
+			a.handleMultiValue(usage, spec.Values[0], i)
+			continue
 		}
-		a.handleAssignedValue(usage, value)
+		a.handleAssignedValue(usage, spec.Values[i])
 	}
 }
 
 func (a *analyzer) handleAssignedValue(usage *mapUsage, expr ast.Expr) {
 	lit, ok := expr.(*ast.CompositeLit)
 	if !ok {
+		a.handleAssignedExpr(usage, expr)
 		return
 	}
-	if !isStringBoolMap(a.pass.TypesInfo.TypeOf(lit)) {
+	if !isBoolMap(a.pass.TypesInfo.TypeOf(lit)) {
 		return
 	}
+	a.handleDeclaredType(usage, lit.Type, 1)
+	if usage.literals == nil {
+		usage.literals = make(map[*ast.CompositeLit]int)
+	}
+	usage.literals[lit] = len(lit.Elts)
 	for _, elt := range lit.Elts {
 		kv, ok := elt.(*ast.KeyValueExpr)
 		if !ok {
@@ -175,9 +1047,488 @@ func (a *analyzer) handleAssignedValue(usage *mapUsage, expr ast.Expr) {
 		}
 		usage.sawWrite = true
 		if !a.isConstTrue(kv.Value) {
-			usage.onlyTrue = false
+			usage.writeOther(kv.Value)
+			continue
+		}
+		usage.edit(kv.Value, "{}")
+	}
+}
+
+// handleUnkeyedFields handles the map fields that a struct literal without
+// keys sets by position. Keyed fields are reached through their names in
+// handleIdent.
+func (a *analyzer) handleUnkeyedFields(lit *ast.CompositeLit) {
+	if len(lit.Elts) == 0 {
+		return
+	}
+	if _, ok := lit.Elts[0].(*ast.KeyValueExpr); ok {
+		return
+	}
+	typ := a.pass.TypesInfo.TypeOf(lit)
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	st, ok := typ.Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+	for i, elt := range lit.Elts {
+		if i >= st.NumFields() {
+			break
+		}
+		if usage := a.usage(st.Field(i)); usage != nil {
+			a.handleAssignedValue(usage, elt)
+		}
+	}
+}
+
+// handleAssignedExpr handles values other than composite literals. Only nil
+// and make calls that spell out the map type can be rewritten. Maps
+// returned by calls are followed through the callee's summary; any other
+// source, such as another variable, is an alias the analyzer does not
+// track.
+func (a *analyzer) handleAssignedExpr(usage *mapUsage, expr ast.Expr) {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident:
+		if _, ok := a.pass.TypesInfo.Uses[e].(*types.Nil); ok {
+			return
+		}
+		if usage.shared && a.usage(a.pass.TypesInfo.Uses[e]) == usage {
+			return
+		}
+	case *ast.CallExpr:
+		if a.isBuiltin(e, "make") && len(e.Args) > 0 {
+			a.handleDeclaredType(usage, e.Args[0], 1)
+			return
+		}
+		usage.fixable = false
+		if a.isFunc(e, "maps", "Clone") && len(e.Args) == 1 {
+			if src := a.usage(a.mapObject(ast.Unparen(e.Args[0]))); src != nil {
+				a.unify(usage, src)
+				return
+			}
+			usage.escaped = true
+			return
+		}
+		a.receive(usage, e, 0)
+		return
+	}
+	usage.fixable = false
+	usage.escaped = true
+}
+
+// handleMultiValue handles a map assigned from result i of a call returning
+// several values. Comma-ok forms yield no map here.
+func (a *analyzer) handleMultiValue(usage *mapUsage, expr ast.Expr, i int) {
+	usage.fixable = false
+	if call, ok := ast.Unparen(expr).(*ast.CallExpr); ok {
+		a.receive(usage, call, i)
+		return
+	}
+	usage.escaped = true
+}
+
+// handleDeclaredType records the edit turning the bool value type of a
+// spelled-out map type into struct{}. A type shared by several names, or
+// one spelled through a named type, cannot be changed for this map alone.
+func (a *analyzer) handleDeclaredType(usage *mapUsage, typ ast.Expr, names int) {
+	if usage.shared && namedSet(a.pass.TypesInfo.TypeOf(typ)) != nil {
+		// The named type is rewritten at its declaration.
+		return
+	}
+	m, ok := typ.(*ast.MapType)
+	if !ok || names != 1 {
+		usage.fixable = false
+		return
+	}
+	usage.edit(m.Value, "struct{}")
+}
+
+// handleIdent classifies every other appearance of a candidate map. Reads
+// are either presence checks (m[k], _, ok := m[k], len(m), range over keys)
+// or value reads that depend on the stored bool. It also checks whether the
+// use keeps working, possibly rewritten, once the map holds struct{}.
+func (a *analyzer) handleIdent(cur inspector.Cursor, ident *ast.Ident) {
+	if obj := a.pass.TypesInfo.Defs[ident]; obj != nil {
+		if usage := a.usages[obj]; usage != nil {
+			a.handleDef(cur, usage)
+		}
+		return
+	}
+	obj := a.pass.TypesInfo.Uses[ident]
+	if isTypeName(obj) {
+		// The name of a set type in a literal, conversion or declaration.
+		return
+	}
+	usage := a.usage(obj)
+	if usage == nil {
+		return
+	}
+
+	// Struct fields are reached through a selector; look at how the whole
+	// selector expression is used.
+	if kind, _ := cur.ParentEdge(); kind == edge.SelectorExpr_Sel {
+		cur = cur.Parent()
+	}
+	usage.uses = append(usage.uses, cur)
+	// A clone is used like the map itself; handleAssignedExpr links the
+	// two when it is assigned.
+	cloned := false
+	for {
+		kind, _ := cur.ParentEdge()
+		if kind != edge.CallExpr_Args || !a.isFunc(cur.Parent().Node().(*ast.CallExpr), "maps", "Clone") {
+			break
+		}
+		cur = cur.Parent()
+		cloned = true
+		usage.fixable = false
+	}
+	kind, index := cur.ParentEdge()
+	parent := cur.Parent()
+	switch kind {
+	case edge.IndexExpr_X:
+		a.handleIndex(parent, usage)
+	case edge.AssignStmt_Lhs:
+		// Assignments of whole maps are handled by handleAssignStmt.
+	case edge.KeyValueExpr_Key:
+		// A field set in a struct literal.
+		a.handleAssignedValue(usage, parent.Node().(*ast.KeyValueExpr).Value)
+	case edge.AssignStmt_Rhs:
+		// _ = m keeps the map alive without using it; any other
+		// assignment makes an alias.
+		assign := parent.Node().(*ast.AssignStmt)
+		if len(assign.Lhs) != len(assign.Rhs) {
+			usage.escaped = true
+			usage.fixable = false
+		} else if lhs := assign.Lhs[index]; !isBlank(lhs) && !a.assignedTo(usage, a.objectForAssignLHS(lhs), cloned) {
+			usage.escaped = true
+			usage.fixable = false
+		}
+	case edge.ValueSpec_Values:
+		spec := parent.Node().(*ast.ValueSpec)
+		if len(spec.Names) != len(spec.Values) || !a.assignedTo(usage, a.pass.TypesInfo.Defs[spec.Names[index]], cloned) {
+			usage.escaped = true
+			usage.fixable = false
+		}
+	case edge.CallExpr_Args:
+		call := parent.Node().(*ast.CallExpr)
+		switch {
+		case a.isBuiltin(call, "len"), a.isBuiltin(call, "delete"), a.isBuiltin(call, "clear"):
+		case a.isFunc(call, "maps", "Copy") && len(call.Args) == 2:
+			// maps.Copy(dst, src) moves entries from src to dst.
+			if other := a.usage(a.mapObject(call.Args[1-index])); other != nil {
+				a.unify(usage, other)
+			} else {
+				usage.escaped = true
+			}
+			usage.fixable = false
+		default:
+			// Maps handed to reflect or unsafe code have no facts and
+			// escape here too.
+			a.passTo(usage, call, index)
+			usage.fixable = false
+		}
+	case edge.RangeStmt_X:
+		rng := parent.Node().(*ast.RangeStmt)
+		if !isBlank(rng.Value) {
+			usage.readValue(rng.Value)
+			usage.fixable = false
+		}
+	case edge.ReturnStmt_Results:
+		// handleReturn links the map to the function's result, whose
+		// type only changes with it for named set types.
+		usage.returned = true
+		if !usage.shared {
+			usage.fixable = false
+		}
+	case edge.BinaryExpr_X, edge.BinaryExpr_Y:
+		// m == nil and m != nil are the only comparisons maps allow.
+	case edge.SelectorExpr_X:
+		// Methods of a named set type declared here are part of its
+		// usage.
+		sel := a.pass.TypesInfo.Selections[parent.Node().(*ast.SelectorExpr)]
+		if !usage.shared || sel == nil || sel.Obj().Pkg() != a.pass.Pkg {
+			usage.escaped = true
+			usage.fixable = false
+		}
+	default:
+		usage.escaped = true
+		usage.fixable = false
+	}
+}
+
+// handleReturn links the maps returned by a function declaration to its
+// result summaries.
+func (a *analyzer) handleReturn(cur inspector.Cursor, ret *ast.ReturnStmt) {
+	var fn *types.Func
+	for enclosing := range cur.Enclosing((*ast.FuncDecl)(nil), (*ast.FuncLit)(nil)) {
+		if decl, ok := enclosing.Node().(*ast.FuncDecl); ok {
+			fn, _ = a.pass.TypesInfo.Defs[decl.Name].(*types.Func)
+		}
+		break
+	}
+	if fn == nil {
+		return
+	}
+	results := fn.Signature().Results()
+	for i := range results.Len() {
+		if !isBoolMap(results.At(i).Type()) {
+			continue
+		}
+		switch {
+		case len(ret.Results) == 0:
+			// A bare return hands back the named result.
+			if usage := a.usages[results.At(i)]; usage != nil {
+				a.links = append(a.links, link{from: usage, to: a.result(fn, i)})
+			}
+		case len(ret.Results) != results.Len():
+			a.returnMultiValue(a.result(fn, i), ret.Results[0], i)
+		default:
+			a.returnValue(a.result(fn, i), ret.Results[i])
+		}
+	}
+}
+
+// returnValue folds a returned expression into the result summary. Local
+// maps and fresh literals move to the caller; parameters, fields and
+// package variables stay shared with other code, so returning them is an
+// alias the analyzer does not follow.
+func (a *analyzer) returnValue(result *mapUsage, expr ast.Expr) {
+	switch e := ast.Unparen(expr).(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		if ident, ok := e.(*ast.Ident); ok {
+			if _, ok := a.pass.TypesInfo.Uses[ident].(*types.Nil); ok {
+				return
+			}
+		}
+		obj := a.mapObject(e)
+		usage := a.usage(obj)
+		if usage == nil {
+			result.escaped = true
+			return
+		}
+		if !usage.shared && (usage.param || !isLocal(a.pass.Pkg, obj)) {
+			usage.escaped = true
+			result.escaped = true
+			return
+		}
+		a.links = append(a.links, link{from: usage, to: result})
+	case *ast.CompositeLit:
+		lit := newUsage()
+		a.handleAssignedValue(lit, e)
+		a.links = append(a.links, link{from: lit, to: result})
+	case *ast.CallExpr:
+		if !a.isBuiltin(e, "make") {
+			a.receive(result, e, 0)
+		}
+	default:
+		result.escaped = true
+	}
+}
+
+func (a *analyzer) returnMultiValue(result *mapUsage, expr ast.Expr, i int) {
+	if call, ok := ast.Unparen(expr).(*ast.CallExpr); ok {
+		a.receive(result, call, i)
+		return
+	}
+	result.escaped = true
+}
+
+func isLocal(pkg *types.Package, obj types.Object) bool {
+	v, ok := obj.(*types.Var)
+	return ok && !v.IsField() && v.Parent() != nil && v.Parent() != pkg.Scope()
+}
+
+// handleDef checks where a candidate is declared. Variables declared by
+// var specs, := and struct fields can be retyped; parameters and results
+// get their type from the signature, and range variables hold maps from
+// elsewhere.
+func (a *analyzer) handleDef(cur inspector.Cursor, usage *mapUsage) {
+	if usage.shared {
+		// Declarations spell the named type, which the fix rewrites.
+		return
+	}
+	kind, _ := cur.ParentEdge()
+	switch kind {
+	case edge.ValueSpec_Names, edge.AssignStmt_Lhs:
+		return
+	case edge.Field_Names:
+		field := cur.Parent()
+		if list, _ := field.ParentEdge(); list == edge.FieldList_List {
+			switch owner, _ := field.Parent().ParentEdge(); owner {
+			case edge.StructType_Fields:
+				a.handleDeclaredType(usage, field.Node().(*ast.Field).Type, len(field.Node().(*ast.Field).Names))
+				return
+			case edge.FuncType_Params:
+				usage.param = true
+			case edge.FuncType_Results:
+				usage.returned = true
+			default:
+				usage.escaped = true
+			}
+		}
+	default:
+		usage.escaped = true
+	}
+	usage.fixable = false
+}
+
+// handleIndex checks a read m[k]. Writes m[k] = v are handled by
+// handleAssignStmt.
+func (a *analyzer) handleIndex(index inspector.Cursor, usage *mapUsage) {
+	expr := index.Node().(*ast.IndexExpr)
+	kind, _ := index.ParentEdge()
+	parent := index.Parent()
+	switch kind {
+	case edge.AssignStmt_Lhs:
+		return
+	case edge.AssignStmt_Rhs:
+		// _, ok := m[k] checks presence and works unchanged, while
+		// v, ok := m[k] reads the stored value.
+		assign := parent.Node().(*ast.AssignStmt)
+		if len(assign.Lhs) == 2 {
+			if isBlank(assign.Lhs[0]) {
+				return
+			}
+			usage.readValue(assign.Lhs[0])
+		}
+	case edge.ValueSpec_Values:
+		spec := parent.Node().(*ast.ValueSpec)
+		if len(spec.Names) == 2 {
+			if isBlank(spec.Names[0]) {
+				return
+			}
+			usage.readValue(spec.Names[0])
+		}
+	case edge.IfStmt_Cond:
+		if a.rewriteCond(parent.Node().(*ast.IfStmt), expr, false, usage) {
+			return
+		}
+	case edge.UnaryExpr_X:
+		unary := parent.Node().(*ast.UnaryExpr)
+		if unary.Op == token.NOT {
+			if cond, _ := parent.ParentEdge(); cond == edge.IfStmt_Cond {
+				if a.rewriteCond(parent.Parent().Node().(*ast.IfStmt), expr, true, usage) {
+					return
+				}
+			}
+		}
+	}
+	usage.fixable = false
+}
+
+// rewriteCond turns if m[k] into if _, ok := m[k]; ok, and if !m[k] into
+// if _, ok := m[k]; !ok. It needs the if statement to have no init.
+func (a *analyzer) rewriteCond(stmt *ast.IfStmt, index *ast.IndexExpr, negate bool, usage *mapUsage) bool {
+	if stmt.Init != nil {
+		return false
+	}
+	src, ok := a.source(index)
+	if !ok {
+		return false
+	}
+	name := a.freeName(stmt, "ok", "found", "present")
+	cond := name
+	if negate {
+		cond = "!" + name
+	}
+	usage.edit(stmt.Cond, "_, "+name+" := "+src+"; "+cond)
+	return true
+}
+
+// freeName returns the first candidate not already used inside node, so
+// the new variable neither shadows nor is shadowed by anything there.
+func (a *analyzer) freeName(node ast.Node, candidates ...string) string {
+	used := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			used[ident.Name] = true
+		}
+		return true
+	})
+	for _, name := range candidates {
+		if !used[name] {
+			return name
+		}
+	}
+	for i := 1; ; i++ {
+		name := candidates[0] + strconv.Itoa(i)
+		if !used[name] {
+			return name
+		}
+	}
+}
+
+// source returns the text of node as written in its file.
+func (a *analyzer) source(node ast.Node) (string, bool) {
+	tf := a.pass.Fset.File(node.Pos())
+	if tf == nil {
+		return "", false
+	}
+	content, ok := a.files[tf.Name()]
+	if !ok {
+		var err error
+		content, err = a.pass.ReadFile(tf.Name())
+		if err != nil {
+			return "", false
+		}
+		a.files[tf.Name()] = content
+	}
+	start, end := tf.Offset(node.Pos()), tf.Offset(node.End())
+	if end > len(content) {
+		return "", false
+	}
+	return string(content[start:end]), true
+}
+
+// assignedTo reports whether a map may be assigned to obj without being
+// lost track of: a clone linked to a tracked map, or another value of the
+// same named set type.
+func (a *analyzer) assignedTo(usage *mapUsage, obj types.Object, cloned bool) bool {
+	dst := a.usage(obj)
+	if dst == nil {
+		return false
+	}
+	return cloned || dst == usage && usage.shared
+}
+
+// isFunc reports whether call calls the package-level function pkg.name.
+func (a *analyzer) isFunc(call *ast.CallExpr, pkg, name string) bool {
+	fn := typeutil.StaticCallee(a.pass.TypesInfo, call)
+	return fn != nil && fn.Pkg() != nil && fn.Pkg().Path() == pkg && fn.Name() == name && fn.Signature().Recv() == nil
+}
+
+func (a *analyzer) isBuiltin(call *ast.CallExpr, name string) bool {
+	ident, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok || ident.Name != name {
+		return false
+	}
+	_, ok = a.pass.TypesInfo.Uses[ident].(*types.Builtin)
+	return ok
+}
+
+func isBlank(expr ast.Expr) bool {
+	if expr == nil {
+		return true
+	}
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "_"
+}
+
+// sortedEdits orders edits by position and drops duplicates, which arise
+// when the same literal is seen both as a declaration and an assignment.
+func sortedEdits(edits []analysis.TextEdit) []analysis.TextEdit {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].Pos < edits[j].Pos
+	})
+	out := edits[:0]
+	for i, e := range edits {
+		if i > 0 && e.Pos == edits[i-1].Pos && e.End == edits[i-1].End {
+			continue
 		}
+		out = append(out, e)
 	}
+	return out
 }
 
 func (a *analyzer) valueForIndex(stmt *ast.AssignStmt, idx int) ast.Expr {
@@ -243,7 +1594,9 @@ func (a *analyzer) isConstTrue(expr ast.Expr) bool {
 	return tv.Value.String() == "true"
 }
 
-func isStringBoolMap(typ types.Type) bool {
+// isBoolMap reports whether typ is a map with bool values. Map keys are
+// always comparable, so any key type qualifies.
+func isBoolMap(typ types.Type) bool {
 	if typ == nil {
 		return false
 	}
@@ -252,10 +1605,6 @@ func isStringBoolMap(typ types.Type) bool {
 	if !ok {
 		return false
 	}
-	key, ok := m.Key().Underlying().(*types.Basic)
-	if !ok || key.Kind() != types.String {
-		return false
-	}
 	elem, ok := m.Elem().Underlying().(*types.Basic)
 	if !ok || elem.Kind() != types.Bool {
 		return false
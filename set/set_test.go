@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/ribice/smgt/internal/fixtest"
+
 	"golang.org/x/tools/go/analysis/analysistest"
 )
 
@@ -17,3 +19,107 @@ func TestAll(t *testing.T) {
 	testdata := filepath.Join(wd, "testdata")
 	analysistest.Run(t, testdata, NewAnalyzer(), "set")
 }
+
+func TestSuggestedFix(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	fixtest.Run(t, testdata, NewAnalyzer(), "setfix")
+}
+
+func TestKeysFlag(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	analyzer := NewAnalyzer()
+	if err := analyzer.Flags.Set("keys", "string,int64"); err != nil {
+		t.Fatalf("Failed to set keys: %s", err)
+	}
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, analyzer, "setkeys")
+}
+
+func TestFlow(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, NewAnalyzer(), "sethelper", "setflow")
+}
+
+func TestEstimateFlag(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	analyzer := NewAnalyzer()
+	if err := analyzer.Flags.Set("estimate", "true"); err != nil {
+		t.Fatalf("Failed to set estimate: %s", err)
+	}
+	testdata := filepath.Join(wd, "testdata")
+	categories := make(map[string]int)
+	for _, result := range analysistest.Run(t, testdata, analyzer, "setestimate") {
+		for _, diag := range result.Diagnostics {
+			categories[diag.Category]++
+		}
+	}
+	if categories["estimate"] != 1 || categories["bool-map"] == 0 || len(categories) != 2 {
+		t.Errorf("categories = %v, want bool-map findings and one estimate summary", categories)
+	}
+}
+
+func TestSwitchFix(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	fixtest.Run(t, testdata, NewAnalyzer(), "setswitch")
+}
+
+func TestPairsFlag(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	analyzer := NewAnalyzer()
+	if err := analyzer.Flags.Set("pairs", "true"); err != nil {
+		t.Fatalf("Failed to set pairs: %s", err)
+	}
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, analyzer, "setpairs")
+}
+
+func TestConfigFile(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, NewAnalyzer(), "setconfig", "setconfig/nested")
+}
+
+func TestConfigFileFlagOverride(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get wd: %s", err)
+	}
+
+	analyzer := NewAnalyzer()
+	if err := analyzer.Flags.Set("keys", "int"); err != nil {
+		t.Fatalf("Failed to set keys: %s", err)
+	}
+	testdata := filepath.Join(wd, "testdata")
+	analysistest.Run(t, testdata, analyzer, "setconfigflag")
+}
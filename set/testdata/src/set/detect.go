@@ -41,7 +41,7 @@ func assignmentWithFalse(keys []string) {
 	}
 }
 
-var packageLevel = map[string]bool{ // want "map\\[string\\]bool variable packageLevel is used as a set; use map\\[string\\]struct\\{\\} instead"
+var packageLevel = map[string]bool{ // want "map\\[string\\]bool variable packageLevel is a constant set of 1 entry; a switch in a predicate function is faster and does not allocate"
 	"alpha": true,
 }
 
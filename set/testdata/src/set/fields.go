@@ -0,0 +1,34 @@
+package set
+
+// cfg is built with an unkeyed literal that stores false, so flags is not
+// a set.
+type cfg struct {
+	flags map[string]bool
+}
+
+func newCfg() cfg {
+	return cfg{map[string]bool{"x": false}}
+}
+
+func (c cfg) enable(k string) {
+	c.flags[k] = true
+}
+
+func (c cfg) has(k string) bool {
+	_, ok := c.flags[k]
+	return ok
+}
+
+type opts struct {
+	name string
+	tags map[string]bool // want "map\\[string\\]bool field tags is used as a set; use map\\[string\\]struct\\{\\} instead"
+}
+
+func newOpts() *opts {
+	return &opts{"n", map[string]bool{"a": true}}
+}
+
+func (o *opts) tagged(k string) bool {
+	_, ok := o.tags[k]
+	return ok
+}
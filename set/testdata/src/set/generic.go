@@ -0,0 +1,58 @@
+package set
+
+func Uniq[T comparable](xs []T) []T {
+	seen := map[T]bool{} // want "map\\[T\\]bool variable seen is used as a set; use map\\[T\\]struct\\{\\} instead"
+	var out []T
+	for _, x := range xs {
+		if seen[x] {
+			continue
+		}
+		seen[x] = true
+		out = append(out, x)
+	}
+	return out
+}
+
+func Count[T comparable](xs []T) map[T]bool { // want Count:`mapFlow\(result 0: writes other\)`
+	m := make(map[T]bool)
+	for i, x := range xs {
+		m[x] = i%2 == 0
+	}
+	return m
+}
+
+type Set[K comparable] map[K]bool // want Set:"set" "map\\[K\\]bool type Set is used as a set; use map\\[K\\]struct\\{\\} instead"
+
+func (s Set[K]) Add(k K) {
+	s[k] = true
+}
+
+func (s Set[K]) Has(k K) bool {
+	_, ok := s[k]
+	return ok
+}
+
+func NewSet[K comparable](keys ...K) Set[K] { // want NewSet:`mapFlow\(result 0: writes true\)`
+	s := make(Set[K], len(keys))
+	for _, k := range keys {
+		s.Add(k)
+	}
+	return s
+}
+
+func names() bool {
+	s := Set[string]{"a": true}
+	s.Add("b")
+	return NewSet(1, 2).Has(3) && s.Has("a")
+}
+
+type Flags[K comparable] map[K]bool
+
+func (f Flags[K]) Set(k K, on bool) {
+	f[k] = on
+}
+
+func useFlags() {
+	f := Flags[int]{}
+	f.Set(1, true)
+}
@@ -0,0 +1,73 @@
+package set
+
+import (
+	"maps"
+	"reflect"
+	"unsafe"
+)
+
+func copied(keys []string) int {
+	src := map[string]bool{} // want "map\\[string\\]bool variable src is used as a set; use map\\[string\\]struct\\{\\} instead"
+	for _, k := range keys {
+		src[k] = true
+	}
+	dst := make(map[string]bool) // want "map\\[string\\]bool variable dst is used as a set; use map\\[string\\]struct\\{\\} instead"
+	maps.Copy(dst, src)
+	return len(dst)
+}
+
+func copiedFalse() int {
+	src := map[string]bool{"a": false}
+	dst := map[string]bool{"b": true}
+	maps.Copy(dst, src)
+	return len(dst)
+}
+
+func cloned(keys []string) bool {
+	s := map[string]bool{} // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	for _, k := range keys {
+		s[k] = true
+	}
+	c := maps.Clone(s) // want "map\\[string\\]bool variable c is used as a set; use map\\[string\\]struct\\{\\} instead"
+	c["extra"] = true
+	_, ok := c["x"]
+	return ok
+}
+
+func clonedRead(keys []string) bool {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	c := maps.Clone(s)
+	v, ok := c["x"]
+	return v && ok
+}
+
+func addAll(m map[string]bool, keys []string) { // want addAll:`mapFlow\(param 0: writes true\)`
+	for _, k := range keys {
+		m[k] = true
+	}
+}
+
+func viaHelper(keys []string) int {
+	s := make(map[string]bool, len(keys)) // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	addAll(s, keys)
+	return len(s)
+}
+
+func viaReflect(keys []string) {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	reflect.ValueOf(s).SetMapIndex(reflect.ValueOf("x"), reflect.ValueOf(false))
+}
+
+func viaUnsafe(keys []string) uintptr {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	return uintptr(unsafe.Pointer(&s))
+}
@@ -0,0 +1,52 @@
+package set
+
+type UserID string
+
+type Key struct {
+	Tenant string
+	ID     int
+}
+
+type UUID [16]byte
+
+func int64Keys(ids []int64) {
+	seen := map[int64]bool{} // want "map\\[int64\\]bool variable seen is used as a set; use map\\[int64\\]struct\\{\\} instead"
+	for _, id := range ids {
+		seen[id] = true
+	}
+}
+
+func namedStringKeys(ids []UserID) {
+	admins := make(map[UserID]bool) // want "map\\[UserID\\]bool variable admins is used as a set; use map\\[UserID\\]struct\\{\\} instead"
+	for _, id := range ids {
+		admins[id] = true
+	}
+}
+
+func structKeys(keys []Key) {
+	done := map[Key]bool{} // want "map\\[Key\\]bool variable done is used as a set; use map\\[Key\\]struct\\{\\} instead"
+	for _, k := range keys {
+		done[k] = true
+	}
+}
+
+func arrayKeys(ids []UUID) {
+	known := map[UUID]bool{} // want "map\\[UUID\\]bool variable known is used as a set; use map\\[UUID\\]struct\\{\\} instead"
+	for _, id := range ids {
+		known[id] = true
+	}
+}
+
+func pointerKeys(keys []*Key) {
+	visited := map[*Key]bool{} // want "map\\[\\*Key\\]bool variable visited is used as a set; use map\\[\\*Key\\]struct\\{\\} instead"
+	for _, k := range keys {
+		visited[k] = true
+	}
+}
+
+func intKeysMixed(ids []int) {
+	flags := map[int]bool{}
+	for _, id := range ids {
+		flags[id] = id > 0
+	}
+}
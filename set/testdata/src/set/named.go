@@ -0,0 +1,39 @@
+package set
+
+type toggles map[string]bool
+
+func (t toggles) set(name string, on bool) {
+	t[name] = on
+}
+
+type features struct {
+	enabled toggles
+}
+
+func (f *features) enable(name string) {
+	f.enabled.set(name, true)
+}
+
+type tags map[string]bool // want "map\\[string\\]bool type tags is used as a set; use map\\[string\\]struct\\{\\} instead"
+
+type post struct {
+	tags tags
+}
+
+func tagged(posts []post, tag string) []post {
+	var out []post
+	for _, p := range posts {
+		if _, ok := p.tags[tag]; ok {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func newPost(names ...string) post {
+	p := post{tags: tags{}}
+	for _, n := range names {
+		p.tags[n] = true
+	}
+	return p
+}
@@ -0,0 +1,61 @@
+package set
+
+func presenceReads(keys []string, probe string) (int, bool) {
+	s := map[string]bool{} // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	for _, k := range keys {
+		s[k] = true
+	}
+	_, ok := s[probe]
+	for k := range s {
+		_ = k
+	}
+	for range s {
+	}
+	if s["a"] && !s["b"] {
+		return len(s), ok
+	}
+	return len(s), s[probe]
+}
+
+func commaOkValue(keys []string, probe string) bool {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	v, ok := s[probe]
+	if ok {
+		return v
+	}
+	return !v
+}
+
+func varCommaOkValue(keys []string, probe string) bool {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	var v, ok = s[probe]
+	return v && ok
+}
+
+func rangeValues(keys []string) int {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	n := 0
+	for _, enabled := range s {
+		if enabled {
+			n++
+		}
+	}
+	return n
+}
+
+func returnedMap(keys []string) map[string]bool { // want returnedMap:`mapFlow\(result 0: writes true\)`
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	return s
+}
@@ -0,0 +1,17 @@
+package setconfig
+
+func ints(xs []int) int {
+	seen := map[int]bool{} // want `map\[int\]bool variable seen is used as a set; use map\[int\]struct\{\} instead`
+	for _, x := range xs {
+		seen[x] = true
+	}
+	return len(seen)
+}
+
+func strs(xs []string) int {
+	seen := map[string]bool{}
+	for _, x := range xs {
+		seen[x] = true
+	}
+	return len(seen)
+}
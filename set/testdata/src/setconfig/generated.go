@@ -0,0 +1,9 @@
+package setconfig
+
+func generated(xs []int) int {
+	seen := map[int]bool{}
+	for _, x := range xs {
+		seen[x] = true
+	}
+	return len(seen)
+}
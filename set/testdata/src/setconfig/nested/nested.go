@@ -0,0 +1,17 @@
+package nested
+
+func ints(xs []int) int {
+	seen := map[int]bool{}
+	for _, x := range xs {
+		seen[x] = true
+	}
+	return len(seen)
+}
+
+func strs(xs []string) int {
+	seen := map[string]bool{} // want `map\[string\]bool variable seen is used as a set; use map\[string\]struct\{\} instead`
+	for _, x := range xs {
+		seen[x] = true
+	}
+	return len(seen)
+}
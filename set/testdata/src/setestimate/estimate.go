@@ -0,0 +1,31 @@
+package setestimate // want "estimated saving for package setestimate: 1 byte across 2 literal maps, plus 1 byte per entry across 2 unbounded maps"
+
+type marker struct{}
+
+var markers = map[marker]bool{marker{}: true} // want "map\\[marker\\]bool variable markers is used as a set; use map\\[marker\\]struct\\{\\} instead \\(estimated saving: 1 byte for 1 entry at 1 byte each\\)"
+
+func isReserved(name string) bool {
+	reserved := map[string]bool{ // want "map\\[string\\]bool variable reserved is used as a set; use map\\[string\\]struct\\{\\} instead \\(estimated saving: 0 bytes for 2 entries at 0 bytes each\\)"
+		"admin": true,
+		"root":  true,
+	}
+	_, ok := reserved[name]
+	_, marked := markers[marker{}]
+	return ok && marked
+}
+
+func seen(ids []int64) int {
+	s := map[int64]bool{} // want "map\\[int64\\]bool variable s is used as a set; use map\\[int64\\]struct\\{\\} instead \\(estimated saving: 0 bytes per entry, unbounded as entries are added at run time\\)"
+	for _, id := range ids {
+		s[id] = true
+	}
+	return len(s)
+}
+
+func marked(n int) int {
+	m := make(map[marker]bool) // want "map\\[marker\\]bool variable m is used as a set; use map\\[marker\\]struct\\{\\} instead \\(estimated saving: 1 byte per entry, unbounded as entries are added at run time\\)"
+	for range n {
+		m[marker{}] = true
+	}
+	return len(m)
+}
@@ -0,0 +1,23 @@
+package setfix
+
+// Importers of Tags and Config.Enabled may read the stored values, so
+// neither is rewritten.
+
+type Tags map[string]bool // want Tags:"set" "map\\[string\\]bool type Tags is used as a set; use map\\[string\\]struct\\{\\} instead"
+
+func (t Tags) Add(tag string) {
+	t[tag] = true
+}
+
+type Config struct {
+	Enabled map[string]bool // want Enabled:"set" "map\\[string\\]bool field Enabled is used as a set; use map\\[string\\]struct\\{\\} instead"
+	hidden  map[string]bool // want "map\\[string\\]bool field hidden is used as a set; use map\\[string\\]struct\\{\\} instead"
+}
+
+func (c *Config) Enable(name string) {
+	if c.Enabled == nil {
+		c.Enabled = make(map[string]bool)
+	}
+	c.Enabled[name] = true
+	c.hidden = map[string]bool{name: true}
+}
@@ -0,0 +1,123 @@
+package setfix
+
+func literal(name string) bool {
+	seen := map[string]bool{ // want "map\\[string\\]bool variable seen is used as a set; use map\\[string\\]struct\\{\\} instead"
+		"alpha": true,
+		"beta":  true,
+	}
+	if seen[name] {
+		return true
+	}
+	return false
+}
+
+func writesAndReads(keys []string, probe string) int {
+	s := make(map[string]bool, len(keys)) // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	for _, k := range keys {
+		s[k] = true
+	}
+	if !s[probe] {
+		return -1
+	}
+	if _, ok := s["gamma"]; ok {
+		delete(s, "gamma")
+	}
+	for k := range s {
+		_ = k
+	}
+	return len(s)
+}
+
+func shadowedOk(keys []string) bool {
+	ok := false
+	var s map[string]bool // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	s = map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	if s["x"] {
+		ok = true
+	}
+	return ok
+}
+
+type registry struct {
+	names map[string]bool // want "map\\[string\\]bool field names is used as a set; use map\\[string\\]struct\\{\\} instead"
+}
+
+func (r *registry) add(name string) {
+	if r.names == nil {
+		r.names = map[string]bool{}
+	}
+	r.names[name] = true
+}
+
+func (r *registry) has(name string) bool {
+	if r.names[name] {
+		return true
+	}
+	return false
+}
+
+func returnedRead(keys []string, probe string) bool {
+	s := map[string]bool{} // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	for _, k := range keys {
+		s[k] = true
+	}
+	return s[probe]
+}
+
+func ifWithInit(keys []string, probe func() string) bool {
+	s := map[string]bool{} // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	for _, k := range keys {
+		s[k] = true
+	}
+	if p := probe(); s[p] {
+		return true
+	}
+	return false
+}
+
+func param(s map[string]bool, key string) { // want param:`mapFlow\(param 0: writes true\)`
+	s[key] = true
+}
+
+func escapes(keys []string) {
+	s := map[string]bool{} // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	for _, k := range keys {
+		s[k] = true
+	}
+	param(s, "x")
+}
+
+type nameSet map[string]bool // want "map\\[string\\]bool type nameSet is used as a set; use map\\[string\\]struct\\{\\} instead"
+
+func (s nameSet) add(name string) {
+	s[name] = true
+}
+
+func (s nameSet) has(name string) bool {
+	if s[name] {
+		return true
+	}
+	return false
+}
+
+func newNameSet(names ...string) nameSet { // want newNameSet:`mapFlow\(result 0: writes true\)`
+	s := make(nameSet, len(names))
+	for _, n := range names {
+		s.add(n)
+	}
+	return s
+}
+
+type directory struct {
+	admins nameSet
+}
+
+func (d *directory) isAdmin(name string) bool {
+	if d.admins == nil {
+		d.admins = nameSet{"root": true}
+	}
+	return d.admins.has(name)
+}
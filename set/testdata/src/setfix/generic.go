@@ -0,0 +1,23 @@
+package setfix
+
+type keySet[K comparable] map[K]bool // want "map\\[K\\]bool type keySet is used as a set; use map\\[K\\]struct\\{\\} instead"
+
+func (s keySet[K]) add(k K) {
+	s[k] = true
+}
+
+func dedup[T comparable](xs []T) []T {
+	seen := keySet[T]{}
+	var out []T
+	for _, x := range xs {
+		if !seen[x] {
+			seen.add(x)
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+func defaults() keySet[string] { // want defaults:`mapFlow\(result 0: writes true\)`
+	return keySet[string]{"a": true}
+}
@@ -0,0 +1,105 @@
+package setflow
+
+import (
+	"fmt"
+	"sethelper"
+)
+
+func addedByHelper(keys []string, probe string) bool {
+	s := map[string]bool{} // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	sethelper.AddAll(s, keys)
+	return sethelper.Has(s, probe)
+}
+
+func disabledByHelper(keys []string) {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	sethelper.Disable(s, "x")
+}
+
+func readByHelper(keys []string) bool {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	return sethelper.Enabled(s, "x")
+}
+
+func retainedByHelper(keys []string) {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	sethelper.Retain(s)
+}
+
+func unknownCallee(keys []string) {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	fmt.Println(s)
+}
+
+func fromHelper(keys []string) int {
+	s := sethelper.New(keys...) // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	s["extra"] = true
+	return len(s)
+}
+
+func fromShared() {
+	s := sethelper.Shared()
+	s["extra"] = true
+}
+
+func local(m map[string]bool, key string) { // want local:`mapFlow\(param 0: writes true\)`
+	sethelper.Add(m, key)
+}
+
+func viaLocal(keys []string) {
+	s := map[string]bool{} // want "map\\[string\\]bool variable s is used as a set; use map\\[string\\]struct\\{\\} instead"
+	for _, k := range keys {
+		local(s, k)
+	}
+}
+
+func viaFuncValue(keys []string, add func(map[string]bool, string)) {
+	s := map[string]bool{}
+	for _, k := range keys {
+		add(s, k)
+	}
+	_ = s
+}
+
+func aliased(keys []string) map[string]bool { // want aliased:`mapFlow\(result 0: escapes\)`
+	s := map[string]bool{}
+	t := s
+	for _, k := range keys {
+		s[k] = true
+	}
+	return t
+}
+
+type cache struct {
+	seen map[string]bool
+}
+
+func (c *cache) fill(keys []string) {
+	s := map[string]bool{}
+	for _, k := range keys {
+		s[k] = true
+	}
+	c.seen = s
+	c.seen["x"] = false
+}
+
+var Exported = map[string]bool{"a": true}
+
+func downstream(n sethelper.Names, c *sethelper.Config) bool { // want downstream:`mapFlow\(param 0: writes other\)`
+	n["x"] = true
+	n["y"] = false          // want "type sethelper.Names is used as a set by its package; this use relies on the stored bool values"
+	v, ok := c.Enabled["z"] // want "field sethelper.Config.Enabled is used as a set by its package; this use relies on the stored bool values"
+	return v && ok
+}
@@ -0,0 +1,60 @@
+package sethelper
+
+var retained map[string]bool
+
+func Add(m map[string]bool, key string) { // want Add:`mapFlow\(param 0: writes true\)`
+	m[key] = true
+}
+
+func AddAll(m map[string]bool, keys []string) { // want AddAll:`mapFlow\(param 0: writes true\)`
+	for _, k := range keys {
+		Add(m, k)
+	}
+}
+
+func Disable(m map[string]bool, key string) { // want Disable:`mapFlow\(param 0: writes other\)`
+	m[key] = false
+}
+
+func Has(m map[string]bool, key string) bool { // want Has:`mapFlow\(param 0: unused\)`
+	_, ok := m[key]
+	return ok
+}
+
+func Enabled(m map[string]bool, key string) bool { // want Enabled:`mapFlow\(param 0: reads value\)`
+	v, ok := m[key]
+	return v && ok
+}
+
+func Retain(m map[string]bool) { // want Retain:`mapFlow\(param 0: escapes\)`
+	retained = m
+}
+
+func New(keys ...string) map[string]bool { // want New:`mapFlow\(result 0: writes true\)`
+	m := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		m[k] = true
+	}
+	return m
+}
+
+func Shared() map[string]bool { // want Shared:`mapFlow\(result 0: escapes\)`
+	return retained
+}
+
+type Names map[string]bool // want Names:"set" "map\\[string\\]bool type Names is used as a set; use map\\[string\\]struct\\{\\} instead"
+
+func (n Names) Add(name string) {
+	n[name] = true
+}
+
+type Config struct {
+	Enabled map[string]bool // want Enabled:"set" "map\\[string\\]bool field Enabled is used as a set; use map\\[string\\]struct\\{\\} instead"
+}
+
+func (c *Config) Enable(name string) {
+	if c.Enabled == nil {
+		c.Enabled = make(map[string]bool)
+	}
+	c.Enabled[name] = true
+}
@@ -0,0 +1,22 @@
+package setkeys
+
+type UserID string
+
+func filtered(names []string, ids []int64, users []UserID, ports []int) {
+	byName := map[string]bool{} // want "map\\[string\\]bool variable byName is used as a set; use map\\[string\\]struct\\{\\} instead"
+	byID := map[int64]bool{}    // want "map\\[int64\\]bool variable byID is used as a set; use map\\[int64\\]struct\\{\\} instead"
+	byUser := map[UserID]bool{} // want "map\\[UserID\\]bool variable byUser is used as a set; use map\\[UserID\\]struct\\{\\} instead"
+	byPort := map[int]bool{}
+	for _, n := range names {
+		byName[n] = true
+	}
+	for _, id := range ids {
+		byID[id] = true
+	}
+	for _, u := range users {
+		byUser[u] = true
+	}
+	for _, p := range ports {
+		byPort[p] = true
+	}
+}
@@ -0,0 +1,73 @@
+package setpairs
+
+type event struct {
+	name string
+	on   bool
+}
+
+func toggles(events []event, probe string) string {
+	enabled := map[string]struct{}{} // want "map\\[string\\]struct\\{\\} sets enabled and disabled are mutually exclusive and always checked together; use a single map\\[string\\]bool, or a map to an enum type"
+	disabled := make(map[string]struct{})
+	for _, e := range events {
+		if e.on {
+			enabled[e.name] = struct{}{}
+			delete(disabled, e.name)
+		} else {
+			disabled[e.name] = struct{}{}
+			delete(enabled, e.name)
+		}
+	}
+	if _, ok := enabled[probe]; ok {
+		return "on"
+	}
+	if _, ok := disabled[probe]; ok {
+		return "off"
+	}
+	return "unknown"
+}
+
+func independent(names []string, probe string) bool {
+	seen := map[string]struct{}{}
+	done := map[string]struct{}{}
+	for _, n := range names {
+		seen[n] = struct{}{}
+		done[n] = struct{}{}
+	}
+	_, a := seen[probe]
+	_, b := done[probe]
+	return a && b
+}
+
+func checkedApart(events []event, probe string) bool {
+	allowed := map[string]struct{}{}
+	denied := map[string]struct{}{}
+	for _, e := range events {
+		if e.on {
+			allowed[e.name] = struct{}{}
+			delete(denied, e.name)
+		} else {
+			denied[e.name] = struct{}{}
+			delete(allowed, e.name)
+		}
+	}
+	_, ok := allowed[probe]
+	return ok && len(denied) > 0
+}
+
+func escapes(events []event, probe string, sink func(map[string]struct{})) bool {
+	up := map[string]struct{}{}
+	down := map[string]struct{}{}
+	for _, e := range events {
+		if e.on {
+			up[e.name] = struct{}{}
+			delete(down, e.name)
+		} else {
+			down[e.name] = struct{}{}
+			delete(up, e.name)
+		}
+	}
+	sink(up)
+	_, a := up[probe]
+	_, b := down[probe]
+	return a || b
+}
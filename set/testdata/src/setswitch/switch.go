@@ -0,0 +1,85 @@
+package setswitch
+
+import "strings"
+
+// reservedNames are the user names nobody may register.
+var reservedNames = map[string]bool{ // want "map\\[string\\]bool variable reservedNames is a constant set of 3 entries; a switch in a predicate function is faster and does not allocate"
+	"admin": true,
+	"root":  true,
+	"guest": true,
+}
+
+func allowed(name string) bool {
+	if reservedNames[name] {
+		return false
+	}
+	_, taken := reservedNames[strings.ToLower(name)]
+	return !taken && len(reservedNames) > 0
+}
+
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warn
+)
+
+var loud = map[Level]bool{Warn: true} // want "map\\[Level\\]bool variable loud is a constant set of 1 entry; a switch in a predicate function is faster and does not allocate"
+
+func noisy(l Level) bool {
+	return loud[l]
+}
+
+var mutable = map[string]bool{"a": true} // want "map\\[string\\]bool variable mutable is a constant set of 1 entry; a switch in a predicate function is faster and does not allocate"
+
+func isMutable() bool {
+	return true
+}
+
+func usesMutable(k string) bool {
+	return mutable[k]
+}
+
+var ranged = map[string]bool{"x": true, "y": true} // want "map\\[string\\]bool variable ranged is used as a set; use map\\[string\\]struct\\{\\} instead"
+
+func listRanged() []string {
+	var out []string
+	for k := range ranged {
+		out = append(out, k)
+	}
+	return out
+}
+
+var written = map[string]bool{"a": true} // want "map\\[string\\]bool variable written is used as a set; use map\\[string\\]struct\\{\\} instead"
+
+func init() {
+	written["b"] = true
+}
+
+var large = map[int]bool{1: true, 2: true, 3: true, 4: true, 5: true, 6: true, 7: true, 8: true} // want "map\\[int\\]bool variable large is used as a set; use map\\[int\\]struct\\{\\} instead"
+
+func inLarge(n int) bool {
+	_, ok := large[n]
+	return ok
+}
+
+var pruned = map[string]bool{"a": true, "b": true} // want "map\\[string\\]bool variable pruned is used as a set; use map\\[string\\]struct\\{\\} instead"
+
+func init() {
+	delete(pruned, "a")
+}
+
+func inPruned(k string) bool {
+	return pruned[k]
+}
+
+var cleared = map[string]bool{"a": true} // want "map\\[string\\]bool variable cleared is used as a set; use map\\[string\\]struct\\{\\} instead"
+
+func reset() {
+	clear(cleared)
+}
+
+func inCleared(k string) bool {
+	return cleared[k]
+}